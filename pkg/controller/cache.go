@@ -0,0 +1,499 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fairwindsops/controller-utils/pkg/log"
+)
+
+// ErrCacheNotSynced is returned when a WorkloadCache is read before it has finished its initial sync.
+var ErrCacheNotSynced = errors.New("workload cache has not synced")
+
+// WorkloadCache keeps an in-memory index of workloads up to date using shared informers
+// for the known controller kinds and Pods. Once started and synced, it serves the same
+// results as Client.GetAllTopControllersSummary and Client.GetAllTopControllersWithPods
+// without going back to the API server.
+type WorkloadCache struct {
+	client      Client
+	namespace   string
+	factory     dynamicinformer.DynamicSharedInformerFactory
+	informers   map[schema.GroupKind]cache.SharedIndexInformer
+	topLevel    map[schema.GroupKind]bool
+	podInformer cache.SharedIndexInformer
+
+	// EventDebounce is how long changes to a single workload are collected before
+	// a WorkloadEvent is emitted for it. It defaults to one second.
+	EventDebounce time.Duration
+
+	mu        sync.RWMutex
+	synced    bool
+	workloads map[WorkloadID]*cachedWorkload
+	// podOwners maps the namespace/name of every pod to the ID of its workload.
+	podOwners map[string]WorkloadID
+	// dependents maps the objects on the owner chain of each pod, and the owner it is missing, to the pods.
+	// The IDs have no UID.
+	dependents map[WorkloadID]map[string]bool
+	pending    map[WorkloadID]*Workload
+
+	handlersMu sync.Mutex
+	handlers   []*registeredHandler
+}
+
+type cachedWorkload struct {
+	topController unstructured.Unstructured
+	podSpec       *corev1.PodSpec
+	podMetadata   *metav1.ObjectMeta
+	pods          map[string]unstructured.Unstructured
+	walks         map[string]*ownerWalk
+	ownerPolicy   OwnerPolicy
+	// standalone is set when the top controller itself was seen by an informer,
+	// so the workload is kept even when it has no pods.
+	standalone bool
+}
+
+// NewWorkloadCache creates a WorkloadCache for the given namespace, or for the whole
+// cluster if namespace is empty. Kinds that can't be mapped by the client's RESTMapper are skipped,
+// and pods owned by them are attributed to the last owner that has an informer.
+func NewWorkloadCache(client Client, namespace string, resync time.Duration) (*WorkloadCache, error) {
+	c := &WorkloadCache{
+		client:        client,
+		namespace:     namespace,
+		EventDebounce: time.Second,
+		factory:       dynamicinformer.NewFilteredDynamicSharedInformerFactory(client.Dynamic, resync, namespace, nil),
+		informers:     map[schema.GroupKind]cache.SharedIndexInformer{},
+		topLevel:      map[schema.GroupKind]bool{},
+		workloads:     map[WorkloadID]*cachedWorkload{},
+		podOwners:     map[string]WorkloadID{},
+		dependents:    map[WorkloadID]map[string]bool{},
+		pending:       map[WorkloadID]*Workload{},
+	}
+	for _, kind := range client.registry().Kinds() {
+		fqKind := kind.GroupVersionKind
+		mapping, err := client.RESTMapper.RESTMapping(fqKind.GroupKind(), fqKind.Version)
+		if err != nil {
+			log.GetLogger().V(3).Info("Unable to watch objects of kind " + fqKind.Kind)
+			continue
+		}
+		informer := c.factory.ForResource(mapping.Resource).Informer()
+		_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { c.onControllerChange(nil, obj) },
+			UpdateFunc: func(oldObj, newObj interface{}) { c.onControllerChange(oldObj, newObj) },
+			DeleteFunc: c.onControllerDelete,
+		})
+		if err != nil {
+			return nil, err
+		}
+		c.informers[fqKind.GroupKind()] = informer
+		c.topLevel[fqKind.GroupKind()] = kind.TopLevel
+	}
+	mapping, err := client.restMapping(schema.FromAPIVersionAndKind("v1", "Pod"))
+	if err != nil {
+		return nil, err
+	}
+	c.podInformer = c.factory.ForResource(mapping.Resource).Informer()
+	_, err = c.podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.onPodChange,
+		UpdateFunc: func(_, newObj interface{}) { c.onPodChange(newObj) },
+		DeleteFunc: c.onPodDelete,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Start starts the underlying informers. They run until ctx is cancelled.
+// The index is built once the informers have synced, see WaitForCacheSync.
+func (c *WorkloadCache) Start(ctx context.Context) {
+	c.factory.Start(ctx.Done())
+	go func() {
+		if c.WaitForCacheSync(ctx) {
+			return
+		}
+		log.GetLogger().V(1).Info("Workload cache stopped before it synced")
+	}()
+	go func() {
+		<-ctx.Done()
+		c.factory.Shutdown()
+	}()
+}
+
+// WaitForCacheSync blocks until all informers have synced and the workload index has been built,
+// or until ctx is cancelled. It returns true if the cache is ready to serve reads.
+func (c *WorkloadCache) WaitForCacheSync(ctx context.Context) bool {
+	for gvr, ok := range c.factory.WaitForCacheSync(ctx.Done()) {
+		if !ok {
+			log.GetLogger().V(1).Info("Informer did not sync", "resource", gvr.String())
+			return false
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		c.rebuild()
+		c.synced = true
+	}
+	return true
+}
+
+// HasSynced returns true once the workload index has been built.
+func (c *WorkloadCache) HasSynced() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.synced
+}
+
+// GetAllTopControllersSummary returns a snapshot of all workloads, without their individual pods.
+// The snapshot only holds the workloads of the namespace, or of every watched namespace if it is empty.
+func (c *WorkloadCache) GetAllTopControllersSummary(namespace string) ([]Workload, error) {
+	return c.snapshot(namespace, false)
+}
+
+// GetAllTopControllersWithPods returns a snapshot of all workloads, as well as all pods.
+// Like GetAllTopControllersSummary, an empty namespace returns every watched namespace.
+func (c *WorkloadCache) GetAllTopControllersWithPods(namespace string) ([]Workload, error) {
+	return c.snapshot(namespace, true)
+}
+
+func (c *WorkloadCache) snapshot(namespace string, includePods bool) ([]Workload, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.synced {
+		return nil, ErrCacheNotSynced
+	}
+	workloads := make([]Workload, 0, len(c.workloads))
+	for _, cached := range c.workloads {
+		if namespace != "" && cached.topController.GetNamespace() != namespace {
+			continue
+		}
+		workloads = append(workloads, cached.toWorkload(c.client.registry(), includePods))
+	}
+	return workloads, nil
+}
+
+func (cached *cachedWorkload) toWorkload(registry *KindRegistry, includePods bool) Workload {
+	workload := Workload{
+		ID:            NewWorkloadID(cached.topController),
+		TopController: *cached.topController.DeepCopy(),
+		PodCount:      len(cached.pods),
+		OwnerPolicy:   cached.ownerPolicy,
+		Health:        registry.workloadHealth(cached.topController),
+		typed:         newTypedObjects(),
+	}
+	if cached.podSpec != nil {
+		workload.PodSpec = cached.podSpec.DeepCopy()
+	}
+	if cached.podMetadata != nil {
+		workload.PodMetadata = cached.podMetadata.DeepCopy()
+	}
+	for key, pod := range cached.pods {
+		workload.addWalk(pod, cached.walks[key], includePods)
+		if getPodStatus(pod) == podStatusRunning {
+			workload.RunningPodCount++
+		}
+		workload.PodStatus.add(pod)
+		workload.Restarts.add(pod)
+		workload.Distribution.add(pod, nil)
+		if includePods {
+			workload.Pods = append(workload.Pods, *pod.DeepCopy())
+		}
+	}
+	workload.setResources()
+	return workload
+}
+
+// rebuild recomputes the whole index from the informer stores. Must be called with mu held.
+func (c *WorkloadCache) rebuild() {
+	c.workloads = map[WorkloadID]*cachedWorkload{}
+	c.podOwners = map[string]WorkloadID{}
+	c.dependents = map[WorkloadID]map[string]bool{}
+	for groupKind, informer := range c.informers {
+		if !c.topLevel[groupKind] {
+			continue
+		}
+		for _, obj := range informer.GetStore().List() {
+			if controller, ok := obj.(*unstructured.Unstructured); ok && len(controller.GetOwnerReferences()) == 0 {
+				c.setStandalone(*controller)
+			}
+		}
+	}
+	for _, obj := range c.podInformer.GetStore().List() {
+		if pod, ok := obj.(*unstructured.Unstructured); ok {
+			c.addPod(*pod)
+		}
+	}
+}
+
+func (c *WorkloadCache) onControllerChange(oldObj, newObj interface{}) {
+	controller, ok := newObj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		return
+	}
+	ownersChanged := oldObj == nil
+	if old, ok := oldObj.(*unstructured.Unstructured); ok {
+		ownersChanged = !reflect.DeepEqual(old.GetOwnerReferences(), controller.GetOwnerReferences())
+	}
+	key := NewWorkloadID(*controller)
+	if len(controller.GetOwnerReferences()) == 0 && c.topLevel[controller.GroupVersionKind().GroupKind()] {
+		c.setStandalone(*controller)
+	} else if existing, ok := c.workloads[key]; ok && existing.standalone {
+		// The controller has been adopted, so it is no longer a top level object.
+		c.markDirty(key)
+		existing.standalone = false
+		c.dropIfEmpty(key)
+	}
+	if ownersChanged {
+		c.resolveDependents(key)
+	}
+}
+
+func (c *WorkloadCache) onControllerDelete(obj interface{}) {
+	controller, ok := unwrapDeleted(obj)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		return
+	}
+	key := NewWorkloadID(*controller)
+	if existing, ok := c.workloads[key]; ok {
+		c.markDirty(key)
+		existing.standalone = false
+		c.dropIfEmpty(key)
+	}
+	c.resolveDependents(key)
+}
+
+func (c *WorkloadCache) onPodChange(obj interface{}) {
+	pod, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		return
+	}
+	c.removePod(podKey(*pod))
+	c.addPod(*pod)
+}
+
+func (c *WorkloadCache) onPodDelete(obj interface{}) {
+	pod, ok := unwrapDeleted(obj)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.synced {
+		return
+	}
+	c.removePod(podKey(*pod))
+}
+
+// resolveDependents re-attributes the pods whose owner chain goes through a controller that changed,
+// or that were missing it as an owner.
+func (c *WorkloadCache) resolveDependents(controller WorkloadID) {
+	pods := make([]string, 0, len(c.dependents[controller.withoutUID()]))
+	for key := range c.dependents[controller.withoutUID()] {
+		pods = append(pods, key)
+	}
+	for _, key := range pods {
+		c.removePod(key)
+		item, exists, err := c.podInformer.GetStore().GetByKey(key)
+		if pod, ok := item.(*unstructured.Unstructured); err == nil && exists && ok {
+			c.addPod(*pod)
+		}
+	}
+}
+
+// walkDependencies returns the IDs, without UIDs, of the owners a pod's walk depends on.
+func walkDependencies(namespace string, walk *ownerWalk) []WorkloadID {
+	ids := make([]WorkloadID, 0, len(walk.chain))
+	for _, hop := range walk.chain {
+		ids = append(ids, WorkloadID{
+			Group:     schema.FromAPIVersionAndKind(hop.APIVersion, hop.Kind).Group,
+			Kind:      hop.Kind,
+			Namespace: namespace,
+			Name:      hop.Name,
+		})
+	}
+	if walk.missing != nil {
+		ids = append(ids, ownerWorkloadID(namespace, walk.missing.Owner).withoutUID())
+	}
+	return ids
+}
+
+func (c *WorkloadCache) setStandalone(controller unstructured.Unstructured) {
+	key := NewWorkloadID(controller)
+	c.markDirty(key)
+	existing, ok := c.workloads[key]
+	if !ok {
+		existing = &cachedWorkload{
+			pods:        map[string]unstructured.Unstructured{},
+			walks:       map[string]*ownerWalk{},
+			ownerPolicy: c.client.ownerPolicy(),
+		}
+		c.workloads[key] = existing
+	}
+	existing.standalone = true
+	existing.topController = controller
+	podMetadata, podSpec, err := c.client.registry().GetPodMetadataAndSpec(controller.UnstructuredContent())
+	if err != nil {
+		log.GetLogger().Error(err, "Error retrieving pod spec", controller.GetKind(), key.String())
+		return
+	}
+	if podSpec != nil {
+		existing.podSpec = podSpec
+		existing.podMetadata = podMetadata
+	}
+}
+
+func (c *WorkloadCache) addPod(pod unstructured.Unstructured) {
+	walk := &ownerWalk{}
+	controller, err := c.resolveTopController(pod, walk)
+	if err != nil {
+		// Do not return the error so that the pod is still accounted for.
+		log.GetLogger().Error(err, "An error occured retrieving the top level controller for this pod", pod.GetName(), pod.GetNamespace())
+	}
+	key := NewWorkloadID(controller)
+	c.markDirty(key)
+	existing, ok := c.workloads[key]
+	if !ok {
+		existing = &cachedWorkload{
+			topController: controller,
+			pods:          map[string]unstructured.Unstructured{},
+			walks:         map[string]*ownerWalk{},
+			ownerPolicy:   c.client.ownerPolicy(),
+		}
+		podMetadata, podSpec, err := c.client.registry().GetPodMetadataAndSpec(controller.UnstructuredContent())
+		if err == nil && podSpec == nil {
+			podMetadata, podSpec, err = c.client.registry().GetPodMetadataAndSpec(pod.UnstructuredContent())
+		}
+		if err != nil {
+			log.GetLogger().Error(err, "Error retrieving pod spec", pod.GetName(), pod.GetNamespace())
+		}
+		existing.podSpec = podSpec
+		existing.podMetadata = podMetadata
+		c.workloads[key] = existing
+	}
+	existing.pods[podKey(pod)] = pod
+	existing.walks[podKey(pod)] = walk
+	c.podOwners[podKey(pod)] = key
+	for _, id := range walkDependencies(pod.GetNamespace(), walk) {
+		if c.dependents[id] == nil {
+			c.dependents[id] = map[string]bool{}
+		}
+		c.dependents[id][podKey(pod)] = true
+	}
+}
+
+func (c *WorkloadCache) removePod(key string) {
+	workloadKey, ok := c.podOwners[key]
+	if !ok {
+		return
+	}
+	delete(c.podOwners, key)
+	c.markDirty(workloadKey)
+	if existing, ok := c.workloads[workloadKey]; ok {
+		if walk, ok := existing.walks[key]; ok {
+			pod := existing.pods[key]
+			for _, id := range walkDependencies(pod.GetNamespace(), walk) {
+				delete(c.dependents[id], key)
+				if len(c.dependents[id]) == 0 {
+					delete(c.dependents, id)
+				}
+			}
+		}
+		delete(existing.pods, key)
+		delete(existing.walks, key)
+		c.dropIfEmpty(workloadKey)
+	}
+}
+
+func (c *WorkloadCache) dropIfEmpty(key WorkloadID) {
+	if existing, ok := c.workloads[key]; ok && !existing.standalone && len(existing.pods) == 0 {
+		delete(c.workloads, key)
+	}
+}
+
+// resolveTopController follows the same owner walk as Client.GetTopController, but looks owners up
+// in the informer stores. The walk stops at an object whose owner is of a kind without an informer,
+// since looking it up through the API would hold up every informer handler.
+func (c *WorkloadCache) resolveTopController(obj unstructured.Unstructured, walk *ownerWalk) (unstructured.Unstructured, error) {
+	walk.visit(obj)
+	if len(obj.GetOwnerReferences()) == 0 {
+		return obj, nil
+	}
+	firstOwner, err := c.client.selectOwner(obj, walk)
+	if err != nil {
+		return obj, err
+	}
+	if firstOwner.Kind == "Node" {
+		// Don't treat the node as a valid controller.
+		return obj, nil
+	}
+	informer, ok := c.informers[schema.FromAPIVersionAndKind(firstOwner.APIVersion, firstOwner.Kind).GroupKind()]
+	if !ok {
+		log.GetLogger().V(1).Info("Not resolving an owner of a kind without an informer", "kind", firstOwner.Kind, "object", getControllerKey(obj))
+		return obj, nil
+	}
+	item, exists, err := informer.GetStore().GetByKey(obj.GetNamespace() + "/" + firstOwner.Name)
+	if err != nil {
+		return obj, newOwnerNotFoundError(obj, firstOwner, err)
+	}
+	parent, ok := item.(*unstructured.Unstructured)
+	if !exists || !ok {
+		walk.missing = &DanglingOwner{Object: objectReference(obj), Owner: firstOwner}
+		return obj, newOwnerNotFoundError(obj, firstOwner, nil)
+	}
+	if firstOwner.UID != "" && parent.GetUID() != "" && firstOwner.UID != parent.GetUID() {
+		walk.missing = &DanglingOwner{Object: objectReference(obj), Owner: firstOwner, FoundUID: parent.GetUID()}
+		return obj, newOwnerNotFoundError(obj, firstOwner, errUIDMismatch(firstOwner.UID, parent.GetUID()))
+	}
+	return c.resolveTopController(*parent, walk)
+}
+
+func podKey(pod unstructured.Unstructured) string {
+	return pod.GetNamespace() + "/" + pod.GetName()
+}
+
+func unwrapDeleted(obj interface{}) (*unstructured.Unstructured, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	unst, ok := obj.(*unstructured.Unstructured)
+	return unst, ok
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func findWorkload(workloads []Workload, name string) *Workload {
+	for idx := range workloads {
+		if workloads[idx].TopController.GetName() == name {
+			return &workloads[idx]
+		}
+	}
+	return nil
+}
+
+// startWorkloadCache starts the informers of a cache and waits for it to sync. The informers
+// are shut down when the test ends, so that they don't log into the next test.
+func startWorkloadCache(t *testing.T, workloadCache *WorkloadCache) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		workloadCache.factory.Shutdown()
+	})
+	workloadCache.factory.Start(ctx.Done())
+	assert.True(t, workloadCache.WaitForCacheSync(ctx))
+}
+
+func TestWorkloadCache(t *testing.T) {
+	client, pod, _, _, _ := setupFakeData(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workloadCache, err := NewWorkloadCache(client, "", 0)
+	assert.NoError(t, err)
+	_, err = workloadCache.GetAllTopControllersSummary("")
+	assert.ErrorIs(t, err, ErrCacheNotSynced)
+
+	workloadCache.Start(ctx)
+	assert.True(t, workloadCache.WaitForCacheSync(ctx))
+	workloads, err := workloadCache.GetAllTopControllersSummary("")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(workloads))
+	workloads, err = workloadCache.GetAllTopControllersWithPods("test")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(workloads))
+	dep := findWorkload(workloads, "dep")
+	assert.NotNil(t, dep)
+	assert.Equal(t, 1, dep.PodCount)
+	assert.Len(t, dep.Pods, 1)
+
+	pods := client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("test")
+	newPod := pod.DeepCopy()
+	newPod.SetName("poddy-2")
+	assert.NoError(t, unstructured.SetNestedField(newPod.Object, podStatusRunning, "status", "phase"))
+	_, err = pods.Create(ctx, newPod, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		workloads, err := workloadCache.GetAllTopControllersSummary("test")
+		assert.NoError(t, err)
+		dep := findWorkload(workloads, "dep")
+		return dep != nil && dep.PodCount == 2 && dep.RunningPodCount == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, pods.Delete(ctx, "poddy", metav1.DeleteOptions{}))
+	assert.NoError(t, pods.Delete(ctx, "poddy-2", metav1.DeleteOptions{}))
+	assert.Eventually(t, func() bool {
+		workloads, err := workloadCache.GetAllTopControllersSummary("test")
+		assert.NoError(t, err)
+		dep := findWorkload(workloads, "dep")
+		return dep != nil && dep.PodCount == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWorkloadCacheResolvesDependents(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	ctx := context.TODO()
+	late := unstructured.Unstructured{}
+	late.SetAPIVersion("apps/v1")
+	late.SetKind("Deployment")
+	late.SetName("late")
+	late.SetNamespace("test")
+	late.SetUID("late-uid")
+	lateRS := unstructured.Unstructured{}
+	lateRS.SetAPIVersion("apps/v1")
+	lateRS.SetKind("ReplicaSet")
+	lateRS.SetName("late-rs")
+	lateRS.SetNamespace("test")
+	lateRS.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "late", UID: "late-uid"}})
+	createObject(t, client, replicaSetsResource, lateRS)
+	createObject(t, client, podsResource, newPodWithOwners("late-pod", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "late-rs"}))
+
+	workloadCache, err := NewWorkloadCache(client, "test", 0)
+	assert.NoError(t, err)
+	startWorkloadCache(t, workloadCache)
+	workloads, err := workloadCache.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.NotNil(t, findWorkload(workloads, "late-rs"))
+	workloadCache.mu.RLock()
+	// Only the pods that depend on the missing deployment are resolved again once it is created.
+	assert.Equal(t, map[string]bool{"test/late-pod": true}, workloadCache.dependents[WorkloadID{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "late"}])
+	assert.Equal(t, map[string]bool{"test/poddy": true}, workloadCache.dependents[WorkloadID{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "dep"}])
+	workloadCache.mu.RUnlock()
+
+	// A deployment with the owner's name but another UID doesn't adopt the pod.
+	recreated := late.DeepCopy()
+	recreated.SetUID("other-uid")
+	createObject(t, client, deploymentsResource, *recreated)
+	assert.Eventually(t, func() bool {
+		workloads, err := workloadCache.GetAllTopControllersSummary("test")
+		assert.NoError(t, err)
+		deployment := findWorkload(workloads, "late")
+		return deployment != nil && deployment.PodCount == 0 && findWorkload(workloads, "late-rs") != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deployments := client.Dynamic.Resource(deploymentsResource).Namespace("test")
+	assert.NoError(t, deployments.Delete(ctx, "late", metav1.DeleteOptions{}))
+	createObject(t, client, deploymentsResource, late)
+	assert.Eventually(t, func() bool {
+		workloads, err := workloadCache.GetAllTopControllersSummary("test")
+		assert.NoError(t, err)
+		deployment := findWorkload(workloads, "late")
+		return deployment != nil && deployment.PodCount == 1 && findWorkload(workloads, "late-rs") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
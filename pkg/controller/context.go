@@ -0,0 +1,104 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// The Ctx variants below use ctx instead of Client.Context for a single call. Discovery checks ctx
+// between kinds, pages and owner hops, and returns ctx.Err() once it is done.
+
+// GetAllTopControllersSummaryCtx is GetAllTopControllersSummary with a context for this call.
+func (client Client) GetAllTopControllersSummaryCtx(ctx context.Context, namespace string) ([]Workload, error) {
+	client.Context = ctx
+	return client.GetAllTopControllersSummary(namespace)
+}
+
+// GetAllTopControllersWithPodsCtx is GetAllTopControllersWithPods with a context for this call.
+func (client Client) GetAllTopControllersWithPodsCtx(ctx context.Context, namespace string) ([]Workload, error) {
+	client.Context = ctx
+	return client.GetAllTopControllersWithPods(namespace)
+}
+
+// GetFilteredTopControllersSummaryCtx is GetFilteredTopControllersSummary with a context for this call.
+func (client Client) GetFilteredTopControllersSummaryCtx(ctx context.Context, filter Filter) ([]Workload, error) {
+	client.Context = ctx
+	return client.GetFilteredTopControllersSummary(filter)
+}
+
+// GetFilteredTopControllersWithPodsCtx is GetFilteredTopControllersWithPods with a context for this call.
+func (client Client) GetFilteredTopControllersWithPodsCtx(ctx context.Context, filter Filter) ([]Workload, error) {
+	client.Context = ctx
+	return client.GetFilteredTopControllersWithPods(filter)
+}
+
+// DiscoverWorkloadsCtx is DiscoverWorkloads with a context for this call.
+func (client Client) DiscoverWorkloadsCtx(ctx context.Context, filter Filter, includePods bool) (*DiscoveryResult, error) {
+	client.Context = ctx
+	return client.DiscoverWorkloads(filter, includePods)
+}
+
+// GetAllPersistentVolumeClaimsCtx is GetAllPersistentVolumeClaims with a context for this call.
+func (client Client) GetAllPersistentVolumeClaimsCtx(ctx context.Context, namespace string) ([]unstructured.Unstructured, error) {
+	client.Context = ctx
+	return client.GetAllPersistentVolumeClaims(namespace)
+}
+
+// GetPersistentVolumeClaimUsageCtx is GetPersistentVolumeClaimUsage with a context for this call.
+func (client Client) GetPersistentVolumeClaimUsageCtx(ctx context.Context, namespace string) (*PersistentVolumeClaimReport, error) {
+	client.Context = ctx
+	return client.GetPersistentVolumeClaimUsage(namespace)
+}
+
+// GetConfigDependenciesCtx is GetConfigDependencies with a context for this call.
+func (client Client) GetConfigDependenciesCtx(ctx context.Context, namespace string) (*ConfigDependencyIndex, error) {
+	client.Context = ctx
+	return client.GetConfigDependencies(namespace)
+}
+
+// GetTopControllerCtx is GetTopController with a context for this call.
+func (client Client) GetTopControllerCtx(ctx context.Context, unstructuredObject unstructured.Unstructured, objectCache map[string]unstructured.Unstructured) (unstructured.Unstructured, error) {
+	client.Context = ctx
+	return client.GetTopController(unstructuredObject, objectCache)
+}
+
+// GetOwnerChainCtx is GetOwnerChain with a context for this call.
+func (client Client) GetOwnerChainCtx(ctx context.Context, unstructuredObject unstructured.Unstructured, objectCache map[string]unstructured.Unstructured) (unstructured.Unstructured, OwnerChain, error) {
+	client.Context = ctx
+	return client.GetOwnerChain(unstructuredObject, objectCache)
+}
+
+// GetOwnerGraphCtx is GetOwnerGraph with a context for this call.
+func (client Client) GetOwnerGraphCtx(ctx context.Context, namespace string) (*OwnerGraph, error) {
+	client.Context = ctx
+	return client.GetOwnerGraph(namespace)
+}
+
+// GetOrphanReportCtx is GetOrphanReport with a context for this call.
+func (client Client) GetOrphanReportCtx(ctx context.Context, namespace string) (*OrphanReport, error) {
+	client.Context = ctx
+	return client.GetOrphanReport(namespace)
+}
+
+// context returns Client.Context, or the background context if it isn't set.
+func (client Client) context() context.Context {
+	if client.Context == nil {
+		return context.Background()
+	}
+	return client.Context
+}
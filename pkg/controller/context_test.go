@@ -0,0 +1,71 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCtxVariants(t *testing.T) {
+	client, pod, _, _, _ := setupFakeData(t)
+	dynamic := client.Dynamic.(*fake.FakeDynamicClient)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dynamic.ClearActions()
+	_, err := client.GetAllTopControllersSummaryCtx(ctx, "test")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = client.DiscoverWorkloadsCtx(ctx, Filter{}, true)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = client.GetTopControllerCtx(ctx, pod, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = client.GetOwnerGraphCtx(ctx, "")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = client.GetAllPersistentVolumeClaimsCtx(ctx, "test")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = client.GetPersistentVolumeClaimUsageCtx(ctx, "test")
+	assert.ErrorIs(t, err, context.Canceled)
+	metadataClient := client
+	metadataClient.Metadata = newMetadataClient(t, client)
+	_, err = metadataClient.GetConfigDependenciesCtx(ctx, "test")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, dynamic.Actions())
+
+	// The client's own context is left alone.
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.NotNil(t, findWorkload(workloads, "dep"))
+}
+
+func TestCtxCanceledBetweenKinds(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	dynamic := client.Dynamic.(*fake.FakeDynamicClient)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dynamic.PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		cancel()
+		return false, nil, nil
+	})
+	dynamic.ClearActions()
+	_, err := client.GetAllTopControllersWithPodsCtx(ctx, "test")
+	assert.ErrorIs(t, err, context.Canceled)
+	// Deployments are the first kind, nothing is listed after them.
+	assert.Len(t, dynamic.Actions(), 1)
+}
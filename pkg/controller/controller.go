@@ -16,7 +16,6 @@ package controller
 
 import (
 	"context"
-	"errors"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
@@ -25,60 +24,83 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
 
 	"github.com/fairwindsops/controller-utils/pkg/log"
 )
 
 const podStatusRunning = "Running"
 
-type knownKind struct {
-	kind       string
-	apiVersion string
-}
-
-var knownKinds = []knownKind{{
-	"Deployment", "apps/v1",
-}, {
-	"ReplicaSet", "apps/v1",
-}, {
-	"CronJob", "batch/v1",
-}, {
-	"Job", "batch/v1",
-}, {
-	"DaemonSet", "apps/v1",
-}, {
-	"StatefulSet", "apps/v1",
-}}
-
 // Workload represents a workload in the cluster. It contains the top level object and all of the pods.
 type Workload struct {
+	// ID identifies TopController, and can be used to index and compare results.
+	ID              WorkloadID
 	TopController   unstructured.Unstructured
 	Pods            []unstructured.Unstructured
 	PodSpec         *corev1.PodSpec
 	PodMetadata     *metav1.ObjectMeta
 	PodCount        int
 	RunningPodCount int
+	// PodStatus breaks the pods down by phase and readiness.
+	PodStatus PodStatusBreakdown
+	// Health is the rollout status of TopController. It is nil if its kind has no HealthFunc.
+	Health *WorkloadHealth
+	// Resources are the effective requests and limits of the pods. It is nil if PodSpec is nil.
+	Resources *WorkloadResources
+	// Restarts aggregates container restarts and waiting reasons across the pods.
+	Restarts RestartSummary
+	// Distribution counts the pods per node, and per zone and region when Client.NodeTopology is set.
+	Distribution PodDistribution
+	// OwnerPolicy is the policy that was used for objects with several owners and no controller.
+	OwnerPolicy OwnerPolicy
+	// AmbiguousOwners lists the objects between the pods and TopController that had several owners
+	// and no controller. It is only filled in with OwnerPolicyReportAll.
+	AmbiguousOwners []AmbiguousOwnership
+	// OwnerChains holds the owner chain of every pod, keyed by pod name, e.g. Pod, ReplicaSet, Deployment.
+	// It is only filled in when the pods are included.
+	OwnerChains map[string]OwnerChain
+
+	// typed caches the decoded TopController, see As. It is nil for workloads built by the caller.
+	typed *typedObjects
 }
 
 // Client is used to interact with the Kubernetes API
 type Client struct {
+	// Context is used for every API call. The Ctx variants of the methods use their own context instead.
 	Context    context.Context
 	Dynamic    dynamic.Interface
 	RESTMapper meta.RESTMapper
+	// PageSize is the number of items fetched per list request. DefaultPageSize is used if it is not set.
+	PageSize int64
+	// ListStatsHook, if set, is called after every paginated list with the number of pages and items fetched.
+	// It may be called from several goroutines at once when Concurrency is set.
+	ListStatsHook func(ListStats)
+	// Registry holds the controller kinds to discover. DefaultRegistry is used if it is not set.
+	Registry *KindRegistry
+	// Concurrency is the number of list calls that may run at once during discovery.
+	// Kinds are listed one after another if it is not set.
+	Concurrency int
+	// OwnerPolicy decides which owner to follow when an object has several owners and none is
+	// the controller. OwnerPolicyFirst is used if it is not set.
+	OwnerPolicy OwnerPolicy
+	// ParallelNamespaces splits cluster wide discovery into one list call per namespace and kind,
+	// so that namespaces are listed in parallel too. It only applies when Concurrency is above one.
+	ParallelNamespaces bool
+	// NodeTopology lists the nodes during discovery, so that Workload.Distribution counts pods per zone and region.
+	// If the nodes can't be listed, the failure is recorded in DiscoveryResult and pods are only counted per node.
+	// WorkloadCache only counts pods per node.
+	NodeTopology bool
+	// Metadata, if set, is used to list intermediate kinds such as ReplicaSets and the owners found while walking up
+	// to the top controllers as PartialObjectMetadata. Top level kinds and pods are still listed in full, and
+	// top controllers only found as metadata are fetched in full through Dynamic.
+	Metadata metadata.Interface
+	// Strict makes discovery return the first list or object failure, instead of collecting failures
+	// in DiscoveryResult and carrying on.
+	Strict bool
 }
 
-func (client Client) getAllPods(namespace string) ([]unstructured.Unstructured, error) {
-	fqKind := schema.FromAPIVersionAndKind("v1", "Pod")
-	mapping, err := client.RESTMapper.RESTMapping(fqKind.GroupKind(), fqKind.Version)
-	if err != nil {
-		log.GetLogger().Error(err, "Error retrieving mapping", "v1", "Pod")
-		return nil, err
-	}
-	pods, err := client.Dynamic.Resource(mapping.Resource).Namespace(namespace).List(client.Context, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-	return pods.Items, nil
+func (client Client) getAllPods(namespace string, listOptions metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	return client.listKind(schema.FromAPIVersionAndKind("v1", "Pod"), namespace, listOptions)
 }
 
 func getPodStatus(unst unstructured.Unstructured) string {
@@ -95,105 +117,287 @@ func getPodStatus(unst unstructured.Unstructured) string {
 	return ""
 }
 
-func (client Client) prepCacheWithKnownControllers(namespace string, objectCache map[string]unstructured.Unstructured) error {
-	for _, kind := range knownKinds {
-		err := client.cacheAllObjectsOfKind(kind.apiVersion, kind.kind, namespace, objectCache, true)
-		if err != nil {
-			log.GetLogger().V(3).Info("Unable to prime cache with objects of kind " + kind.kind)
+// prepCacheWithKnownControllers returns one task per top level kind and namespace, each of which
+// lists that kind into the object cache. List failures are recorded and otherwise ignored.
+func (client Client) prepCacheWithKnownControllers(namespaces []string, objectCache *objectCache, listOptions metav1.ListOptions, failures *discoveryFailures) []func() {
+	tasks := []func(){}
+	for _, kind := range client.registry().Kinds() {
+		if !kind.TopLevel {
+			continue
+		}
+		fqKind := kind.GroupVersionKind
+		apiVersion, kindName := fqKind.ToAPIVersionAndKind()
+		// Intermediate kinds are only needed for the ownership walk, so their metadata is enough.
+		full := client.Metadata == nil || !kind.Intermediate
+		for _, namespace := range namespaces {
+			namespace := namespace
+			tasks = append(tasks, func() {
+				if failures.stopped() != nil {
+					return
+				}
+				err := client.cacheAllObjectsOfKind(apiVersion, kindName, namespace, objectCache, full, listOptions)
+				if err != nil {
+					log.GetLogger().V(3).Info("Unable to prime cache with objects of kind " + kindName)
+				}
+				failures.listed(fqKind, namespace, err, false)
+			})
 		}
 	}
-	return nil
+	return tasks
+}
+
+// getNamespacesToList expands a cluster wide discovery into one entry per namespace when
+// ParallelNamespaces is set, so that every namespace can be listed separately.
+func (client Client) getNamespacesToList(selection compiledFilter) []string {
+	namespaces := selection.namespaces()
+	if !client.ParallelNamespaces || client.Concurrency <= 1 || len(namespaces) != 1 || namespaces[0] != "" {
+		return namespaces
+	}
+	objects, err := client.listKind(schema.FromAPIVersionAndKind("v1", "Namespace"), "", metav1.ListOptions{})
+	if err != nil {
+		log.GetLogger().V(3).Info("Unable to list namespaces, listing the whole cluster at once")
+		return namespaces
+	}
+	namespaces = []string{}
+	for _, object := range objects {
+		if selection.matchesNamespace(object.GetName()) {
+			namespaces = append(namespaces, object.GetName())
+		}
+	}
+	return namespaces
 }
 
 // GetAllTopControllersSummary returns the highest level owning object of all pods
 // If a namespace is provided than this is limited to that namespace.
 // This can be more memory-efficient than GetAllTopControllersWithPods, since it does not include individual pods.
 func (client Client) GetAllTopControllersSummary(namespace string) ([]Workload, error) {
-	return client.getAllTopControllers(namespace, false)
+	return client.getWorkloads(namespaceFilter(namespace), false)
 }
 
 // GetAllTopControllersWithPods returns the highest level owning object of all pods, as well as all pods.
 // If a namespace is provided than this is limited to that namespace.
 func (client Client) GetAllTopControllersWithPods(namespace string) ([]Workload, error) {
-	return client.getAllTopControllers(namespace, true)
+	return client.getWorkloads(namespaceFilter(namespace), true)
 }
 
-// GetAllPersistentVolumeClaims returns all PVCs as unstructured objects.
-func (client Client) GetAllPersistentVolumeClaims(namespace string) ([]unstructured.Unstructured, error) {
-	fqKind := schema.FromAPIVersionAndKind("v1", "PersistentVolumeClaim")
-	mapping, err := client.RESTMapper.RESTMapping(fqKind.GroupKind(), fqKind.Version)
+// GetFilteredTopControllersSummary is like GetAllTopControllersSummary, but only returns the workloads
+// and pods that match the filter.
+func (client Client) GetFilteredTopControllersSummary(filter Filter) ([]Workload, error) {
+	return client.getWorkloads(filter, false)
+}
+
+// GetFilteredTopControllersWithPods is like GetAllTopControllersWithPods, but only returns the workloads
+// and pods that match the filter.
+func (client Client) GetFilteredTopControllersWithPods(filter Filter) ([]Workload, error) {
+	return client.getWorkloads(filter, true)
+}
+
+// getWorkloads keeps the behavior from before DiscoverWorkloads: it fails if pods couldn't be listed or a pod
+// template couldn't be decoded, and only logs the other failures.
+func (client Client) getWorkloads(filter Filter, includePods bool) ([]Workload, error) {
+	result, err := client.getAllTopControllers(filter, includePods)
 	if err != nil {
-		log.GetLogger().Error(err, "Error retrieving mapping", "v1", "PersistentVolumeClaim")
 		return nil, err
 	}
-	PVCs, err := client.Dynamic.Resource(mapping.Resource).Namespace(namespace).List(client.Context, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
+	if result.legacyErr != nil {
+		return nil, result.legacyErr
 	}
-	return PVCs.Items, nil
+	return result.Workloads, nil
 }
 
-func (client Client) getAllTopControllers(namespace string, includePods bool) ([]Workload, error) {
-	workloadMap := map[string]Workload{}
-	objectCache := map[string]unstructured.Unstructured{}
-	err := client.prepCacheWithKnownControllers(namespace, objectCache)
+// GetAllPersistentVolumeClaims returns all PVCs as unstructured objects.
+func (client Client) GetAllPersistentVolumeClaims(namespace string) ([]unstructured.Unstructured, error) {
+	return client.listKind(schema.FromAPIVersionAndKind("v1", "PersistentVolumeClaim"), namespace, metav1.ListOptions{})
+}
+
+// getAllTopControllers builds the workloads matching the filter, and reports the pods and controllers
+// whose owners could not be found on the way. Failures are collected in the result, unless Client.Strict is set.
+func (client Client) getAllTopControllers(filter Filter, includePods bool) (*DiscoveryResult, error) {
+	selection, err := filter.compile()
 	if err != nil {
 		return nil, err
 	}
-	for _, controller := range objectCache {
-		key := getControllerKey(controller)
-		podMetadata, podSpec, err := GetPodMetadataAndSpec(controller.UnstructuredContent())
+	failures := &discoveryFailures{strict: client.Strict}
+	orphans := newOrphanCollector()
+	// walked holds the IDs, without UIDs, of the objects the pod walks went through.
+	walked := map[WorkloadID]bool{}
+	workloadMap := map[WorkloadID]Workload{}
+	objectCache := newObjectCache(nil)
+	namespaces := client.getNamespacesToList(selection)
+	tasks := client.prepCacheWithKnownControllers(namespaces, objectCache, selection.controllerListOptions(), failures)
+	namespacePods := make([][]unstructured.Unstructured, len(namespaces))
+	for idx, namespace := range namespaces {
+		idx, namespace := idx, namespace
+		tasks = append(tasks, func() {
+			if failures.stopped() != nil {
+				return
+			}
+			var err error
+			namespacePods[idx], err = client.getAllPods(namespace, selection.podListOptions())
+			failures.listed(schema.FromAPIVersionAndKind("v1", "Pod"), namespace, err, true)
+		})
+	}
+	var nodes map[string]nodeTopology
+	if client.NodeTopology {
+		tasks = append(tasks, func() {
+			if failures.stopped() != nil {
+				return
+			}
+			var err error
+			nodes, err = client.getNodeTopology()
+			// Without nodes, pods are still counted per node, just not per zone and region.
+			failures.listed(schema.FromAPIVersionAndKind("v1", "Node"), "", err, false)
+		})
+	}
+	client.runTasks(tasks)
+	if err := client.context().Err(); err != nil {
+		return nil, err
+	}
+	if err := failures.stopped(); err != nil {
+		return nil, err
+	}
+	// decode reads the pod template of a controller, or of the pod if the controller has none.
+	decode := func(controller unstructured.Unstructured, pod *unstructured.Unstructured) (*metav1.ObjectMeta, *corev1.PodSpec) {
+		podMetadata, podSpec, err := client.registry().GetPodMetadataAndSpec(controller.UnstructuredContent())
+		object := controller
+		if err == nil && podSpec == nil && pod != nil {
+			object = *pod
+			podMetadata, podSpec, err = client.registry().GetPodMetadataAndSpec(pod.UnstructuredContent())
+		}
 		if err != nil {
+			failures.add(DiscoveryFailure{
+				GroupVersionKind: object.GroupVersionKind(),
+				Namespace:        object.GetNamespace(),
+				Name:             object.GetName(),
+				Err:              err,
+			}, true)
+		}
+		return podMetadata, podSpec
+	}
+	controllers := []unstructured.Unstructured{}
+	for _, controller := range objectCache.list() {
+		if len(controller.GetOwnerReferences()) == 0 && selection.matchesNamespace(controller.GetNamespace()) {
+			controllers = append(controllers, controller)
+		}
+	}
+	if client.Metadata != nil {
+		controllers = client.getFullObjects(controllers, failures)
+		if err := client.context().Err(); err != nil {
+			return nil, err
+		}
+	}
+	for _, controller := range controllers {
+		key := NewWorkloadID(controller)
+		podMetadata, podSpec := decode(controller, nil)
+		if err := failures.stopped(); err != nil {
 			return nil, err
 		}
 		workloadMap[key] = Workload{
+			ID:            key,
 			TopController: controller,
 			PodSpec:       podSpec,
 			PodMetadata:   podMetadata,
+			OwnerPolicy:   client.ownerPolicy(),
+			typed:         newTypedObjects(),
 		}
 	}
-	pods, err := client.getAllPods(namespace)
-	if err != nil {
-		return nil, err
+	pods := []unstructured.Unstructured{}
+	for _, items := range namespacePods {
+		pods = append(pods, items...)
 	}
 	// TODO avoid cycling over multiple pods with the same parent
 	for _, pod := range pods {
-		controller, err := client.GetTopController(pod, objectCache)
+		if !selection.matchesNamespace(pod.GetNamespace()) {
+			continue
+		}
+		walk := &ownerWalk{}
+		controller, err := client.getTopController(pod, objectCache, walk)
+		if ctxErr := client.context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		orphans.add(pod, walk)
+		for _, id := range walkDependencies(pod.GetNamespace(), walk) {
+			walked[id] = true
+		}
 		if err != nil {
-			// Do not return the error so that we can retrieve as many top level controllers as possible.
-			log.GetLogger().Error(err, "An error occured retrieving the top level controller for this pod", pod.GetName(), pod.GetNamespace())
+			// Do not stop so that we can retrieve as many top level controllers as possible.
+			failures.add(DiscoveryFailure{
+				GroupVersionKind: pod.GroupVersionKind(),
+				Namespace:        pod.GetNamespace(),
+				Name:             pod.GetName(),
+				Err:              err,
+			}, false)
+			if err := failures.stopped(); err != nil {
+				return nil, err
+			}
 		}
-		key := getControllerKey(controller)
+		key := NewWorkloadID(controller)
 		existingWorkload, ok := workloadMap[key]
 		if !ok {
-			existingWorkload.TopController = controller
-			podMetadata, podSpec, err := GetPodMetadataAndSpec(controller.UnstructuredContent())
-			if err != nil {
-				return nil, err
+			if !selection.matchesController(controller) {
+				continue
 			}
-			if podSpec == nil {
-				podMetadata, podSpec, err = GetPodMetadataAndSpec(pod.UnstructuredContent())
+			if client.isPartial(pod, controller) {
+				full, err := client.getFullObject(controller)
 				if err != nil {
-					return nil, err
+					failures.add(DiscoveryFailure{
+						GroupVersionKind: controller.GroupVersionKind(),
+						Namespace:        controller.GetNamespace(),
+						Name:             controller.GetName(),
+						Err:              err,
+					}, false)
+					if err := failures.stopped(); err != nil {
+						return nil, err
+					}
 				}
+				controller = full
+			}
+			existingWorkload.ID = key
+			existingWorkload.TopController = controller
+			existingWorkload.OwnerPolicy = client.ownerPolicy()
+			existingWorkload.typed = newTypedObjects()
+			existingWorkload.PodMetadata, existingWorkload.PodSpec = decode(controller, &pod)
+			if err := failures.stopped(); err != nil {
+				return nil, err
 			}
-			existingWorkload.PodSpec = podSpec
-			existingWorkload.PodMetadata = podMetadata
 		}
+		existingWorkload.addWalk(pod, walk, includePods)
 		existingWorkload.PodCount++
 		if getPodStatus(pod) == podStatusRunning {
 			existingWorkload.RunningPodCount++
 		}
+		existingWorkload.PodStatus.add(pod)
+		existingWorkload.Restarts.add(pod)
+		existingWorkload.Distribution.add(pod, nodes)
 		if includePods {
 			existingWorkload.Pods = append(existingWorkload.Pods, pod)
 		}
 		workloadMap[key] = existingWorkload
 	}
+	// Owned objects that no pod led to, such as old ReplicaSets, are walked too, so that their missing owners are reported.
+	for _, object := range objectCache.list() {
+		if len(object.GetOwnerReferences()) == 0 || walked[NewWorkloadID(object).withoutUID()] || !selection.matchesNamespace(object.GetNamespace()) {
+			continue
+		}
+		walk := &ownerWalk{}
+		_, err := client.getTopController(object, objectCache, walk)
+		if ctxErr := client.context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			log.GetLogger().V(1).Info("Unable to find the top level controller for this object", object.GetName(), object.GetNamespace())
+		}
+		orphans.add(object, walk)
+	}
 	workloads := make([]Workload, 0)
 	for _, workload := range workloadMap {
+		workload.Health = client.registry().workloadHealth(workload.TopController)
+		workload.setResources()
 		workloads = append(workloads, workload)
 	}
-	return workloads, nil
+	result := failures.result(workloads)
+	result.Orphans = orphans.finish()
+	return result, nil
 }
 
 func getControllerKey(controller unstructured.Unstructured) string {
@@ -201,58 +405,97 @@ func getControllerKey(controller unstructured.Unstructured) string {
 }
 
 // GetTopController finds the highest level owner of whatever object is passed in.
+// The owner reference marked as the controller is followed, see Client.OwnerPolicy for objects without one.
+// If an owner doesn't exist, the last object found is returned with an *OwnerNotFoundError.
+// objectCache, if set, is filled in with the objects that were listed, keyed by kind, namespace and name, and
+// with an entry without a name for every kind and namespace that was listed, so that it can be shared.
 func (client Client) GetTopController(unstructuredObject unstructured.Unstructured, objectCache map[string]unstructured.Unstructured) (unstructured.Unstructured, error) {
+	controller, err := client.getTopController(unstructuredObject, newObjectCache(objectCache), &ownerWalk{})
+	return client.fullTopController(unstructuredObject, controller, err)
+}
+
+// fullTopController fetches the whole of a top controller that was found through Client.Metadata.
+func (client Client) fullTopController(object, controller unstructured.Unstructured, err error) (unstructured.Unstructured, error) {
+	if !client.isPartial(object, controller) || client.context().Err() != nil {
+		return controller, err
+	}
+	full, fetchErr := client.getFullObject(controller)
+	if err == nil {
+		err = fetchErr
+	}
+	return full, err
+}
+
+func (client Client) getTopController(unstructuredObject unstructured.Unstructured, objectCache *objectCache, walk *ownerWalk) (unstructured.Unstructured, error) {
+	if err := client.context().Err(); err != nil {
+		return unstructuredObject, err
+	}
+	walk.visit(unstructuredObject)
 	owners := unstructuredObject.GetOwnerReferences()
 	if len(owners) > 0 {
-		if objectCache == nil {
-			objectCache = map[string]unstructured.Unstructured{}
-		}
-		if len(owners) > 1 {
-			log.GetLogger().V(1).Info("Found more than one owner", unstructuredObject.GetName(), unstructuredObject.GetNamespace())
+		firstOwner, err := client.selectOwner(unstructuredObject, walk)
+		if err != nil {
+			return unstructuredObject, err
 		}
-		firstOwner := owners[0]
 		if firstOwner.Kind == "Node" {
 			// Don't treat the node as a valid controller.
 			// This happens for static pods.
 			return unstructuredObject, nil
 		}
-		key := fmt.Sprintf("%s/%s/%s", firstOwner.Kind, unstructuredObject.GetNamespace(), firstOwner.Name)
-		abstractObject, ok := objectCache[key]
+		ownerID := ownerWorkloadID(unstructuredObject.GetNamespace(), firstOwner)
+		abstractObject, ok := objectCache.get(ownerID)
 		if !ok {
-			err := client.cacheAllObjectsOfKind(firstOwner.APIVersion, firstOwner.Kind, unstructuredObject.GetNamespace(), objectCache, false)
+			// The owner may have been created after its kind was listed, or deleted and created again.
+			err := client.cacheAllObjectsOfKind(firstOwner.APIVersion, firstOwner.Kind, unstructuredObject.GetNamespace(), objectCache, false, metav1.ListOptions{})
 			if err != nil {
+				if meta.IsNoMatchError(err) {
+					// The kind isn't served, so the owner can't exist.
+					walk.missing = &DanglingOwner{Object: objectReference(unstructuredObject), Owner: firstOwner}
+					return unstructuredObject, newOwnerNotFoundError(unstructuredObject, firstOwner, err)
+				}
 				return unstructuredObject, err
 			}
-			abstractObject, ok = objectCache[key]
+			abstractObject, ok = objectCache.get(ownerID)
+			if !ok {
+				abstractObject, ok = objectCache.getByName(ownerID)
+			}
 			if !ok {
-				return unstructuredObject, errors.New("this object could not be found for this object " + key)
+				walk.missing = &DanglingOwner{Object: objectReference(unstructuredObject), Owner: firstOwner}
+				return unstructuredObject, newOwnerNotFoundError(unstructuredObject, firstOwner, nil)
 			}
 		}
-		return client.GetTopController(abstractObject, objectCache)
+		if firstOwner.UID != "" && abstractObject.GetUID() != "" && firstOwner.UID != abstractObject.GetUID() {
+			// The owner was deleted, and another object was created with its name.
+			walk.missing = &DanglingOwner{
+				Object:   objectReference(unstructuredObject),
+				Owner:    firstOwner,
+				FoundUID: abstractObject.GetUID(),
+			}
+			return unstructuredObject, newOwnerNotFoundError(unstructuredObject, firstOwner, errUIDMismatch(firstOwner.UID, abstractObject.GetUID()))
+		}
+		return client.getTopController(abstractObject, objectCache, walk)
 	}
 	return unstructuredObject, nil
 }
 
-func (client Client) cacheAllObjectsOfKind(apiVersion, kind, namespace string, objectCache map[string]unstructured.Unstructured, mustBeTopLevel bool) error {
+// cacheAllObjectsOfKind lists every object of a kind into the cache. When Client.Metadata is set, only their
+// metadata is listed unless full is set, e.g. for top level controllers whose pod templates are needed.
+func (client Client) cacheAllObjectsOfKind(apiVersion, kind, namespace string, objectCache *objectCache, full bool, listOptions metav1.ListOptions) error {
 	log.GetLogger().V(9).Info("cache all", apiVersion, kind)
-	fqKind := schema.FromAPIVersionAndKind(apiVersion, kind)
-	mapping, err := client.RESTMapper.RESTMapping(fqKind.GroupKind(), fqKind.Version)
-	if err != nil {
-		log.GetLogger().Error(err, "Error retrieving mapping", apiVersion, kind)
-		return err
+	list := client.listKind
+	if client.Metadata != nil && !full {
+		list = client.listKindMetadata
 	}
-
-	objects, err := client.Dynamic.Resource(mapping.Resource).Namespace(namespace).List(client.Context, metav1.ListOptions{})
+	objects, err := list(schema.FromAPIVersionAndKind(apiVersion, kind), namespace, listOptions)
 	if err != nil {
-		log.GetLogger().Error(err, "Error retrieving parent object", mapping.Resource.Version, mapping.Resource.Resource)
+		log.GetLogger().Error(err, "Error retrieving parent object", apiVersion, kind)
 		return err
 	}
-	for idx, object := range objects.Items {
-		if mustBeTopLevel && len(object.GetOwnerReferences()) > 0 {
-			continue
-		}
-		key := getControllerKey(object)
-		objectCache[key] = objects.Items[idx]
+	for idx := range objects {
+		objectCache.set(objects[idx])
+	}
+	if listOptions.LabelSelector == "" && listOptions.FieldSelector == "" {
+		objectCache.setListed(schema.FromAPIVersionAndKind(apiVersion, kind), namespace)
 	}
 	return nil
 }
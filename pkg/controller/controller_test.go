@@ -16,9 +16,13 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"sync"
 	"testing"
 
 	testLog "github.com/go-logr/logr/testing"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +34,18 @@ import (
 	"github.com/fairwindsops/controller-utils/pkg/log"
 )
 
+// newFakeClient returns a client whose RESTMapper knows the namespaced kinds, and whose dynamic fake
+// can list the resources in listKinds.
+func newFakeClient(t *testing.T, kinds []schema.GroupVersionKind, listKinds map[schema.GroupVersionResource]string, objects ...runtime.Object) Client {
+	log.SetLogger(testLog.NewTestLogger(t))
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range kinds {
+		restMapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	dynamic := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+	return Client{Dynamic: dynamic, RESTMapper: restMapper, Context: context.TODO()}
+}
+
 func setupFakeData(t *testing.T) (Client, unstructured.Unstructured, unstructured.Unstructured, unstructured.Unstructured, unstructured.Unstructured) {
 
 	// TODO move to a centralized place
@@ -122,8 +138,18 @@ func setupFakeData(t *testing.T) (Client, unstructured.Unstructured, unstructure
 			{Group: "apps", Version: "v1", Resource: "replicasets"}: "ReplicaSetList",
 			{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
 			{Group: "", Version: "v1", Resource: "pods"}:            "PodsList",
+			{Group: "", Version: "v1", Resource: "namespaces"}:      "NamespaceList",
+			{Group: "", Version: "v1", Resource: "nodes"}:           "NodeList",
 		},
 	)
+	for _, name := range []string{"test", "test2"} {
+		namespace := unstructured.Unstructured{}
+		namespace.SetAPIVersion("v1")
+		namespace.SetKind("Namespace")
+		namespace.SetName(name)
+		_, err := dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}).Create(context.TODO(), &namespace, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
 	mapping, err := restMapper.RESTMapping(gvpod.WithKind("Pod").GroupKind())
 	assert.NoError(t, err)
 	_, err = dynamic.Resource(mapping.Resource).Namespace("test").Create(context.TODO(), &pod, metav1.CreateOptions{})
@@ -172,3 +198,62 @@ func TestGetAllTopControllers(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 2, len(controllers))
 }
+
+func TestGetFilteredTopControllers(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	controllers, err := client.GetFilteredTopControllersSummary(Filter{ExcludedNamespaces: []string{"test2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(controllers))
+	controllers, err = client.GetFilteredTopControllersSummary(Filter{Namespaces: []string{"test", "test2"}, ExcludedNamespaces: []string{"test"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(controllers))
+	assert.Equal(t, "poddy-bad", controllers[0].TopController.GetName())
+
+	controllers, err = client.GetFilteredTopControllersSummary(Filter{LabelSelector: "app=nothing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(controllers))
+
+	controllers, err = client.GetFilteredTopControllersWithPods(Filter{Namespaces: []string{"test"}, PodLabelSelector: "app=nothing"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(controllers))
+	for _, controller := range controllers {
+		assert.Equal(t, 0, controller.PodCount)
+		assert.Empty(t, controller.Pods)
+	}
+
+	_, err = client.GetFilteredTopControllersSummary(Filter{LabelSelector: "app in ("})
+	assert.Error(t, err)
+}
+
+func summarizeWorkloads(workloads []Workload) []string {
+	summary := lo.Map(workloads, func(workload Workload, _ int) string {
+		return fmt.Sprintf("%s pods=%d", getControllerKey(workload.TopController), workload.PodCount)
+	})
+	sort.Strings(summary)
+	return summary
+}
+
+func TestGetAllTopControllersConcurrently(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	client.RESTMapper.(*meta.DefaultRESTMapper).Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	sequential, err := client.GetAllTopControllersWithPods("")
+	assert.NoError(t, err)
+
+	client.Concurrency = 4
+	concurrent, err := client.GetAllTopControllersWithPods("")
+	assert.NoError(t, err)
+	assert.Equal(t, summarizeWorkloads(sequential), summarizeWorkloads(concurrent))
+
+	listed := []string{}
+	var mu sync.Mutex
+	client.ParallelNamespaces = true
+	client.ListStatsHook = func(stats ListStats) {
+		mu.Lock()
+		defer mu.Unlock()
+		listed = append(listed, stats.Resource.Resource+"/"+stats.Namespace)
+	}
+	concurrent, err = client.GetAllTopControllersWithPods("")
+	assert.NoError(t, err)
+	assert.Equal(t, summarizeWorkloads(sequential), summarizeWorkloads(concurrent))
+	assert.Subset(t, listed, []string{"deployments/test", "deployments/test2", "pods/test", "pods/test2"})
+}
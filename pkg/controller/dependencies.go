@@ -0,0 +1,231 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// rootCACertConfigMap is published in every namespace, and mounted with the service account token.
+const rootCACertConfigMap = "kube-root-ca.crt"
+
+// ConfigReference identifies a ConfigMap or Secret.
+type ConfigReference struct {
+	// Kind is ConfigMap or Secret.
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (reference ConfigReference) String() string {
+	return reference.Kind + "/" + objectName(reference.Namespace, reference.Name)
+}
+
+// ConfigDependencyIndex maps workloads to the ConfigMaps and Secrets they reference, and back.
+type ConfigDependencyIndex struct {
+	// ByWorkload lists the objects referenced by each workload, sorted.
+	ByWorkload map[WorkloadID][]ConfigReference
+	// ByObject lists the workloads referencing each object, sorted by ID. It includes objects that don't exist.
+	ByObject map[ConfigReference][]WorkloadID
+	// Missing lists referenced objects that don't exist, unless every reference to them is optional.
+	Missing []ConfigReference
+	// Unreferenced lists ConfigMaps and Secrets that no workload references.
+	Unreferenced []ConfigReference
+}
+
+// ErrMetadataClientRequired is returned by GetConfigDependencies when Client.Metadata isn't set.
+var ErrMetadataClientRequired = errors.New("a metadata client is required to list secrets without their data")
+
+// serviceAccount holds what a pod using a service account mounts along with its token.
+type serviceAccount struct {
+	// tokens are the legacy token Secrets of the service account.
+	tokens []ConfigReference
+	// noAutomount is set when the service account disables automounting its token.
+	noAutomount bool
+}
+
+// GetConfigDependencies indexes the ConfigMaps and Secrets referenced by the PodSpec of every workload, through
+// env and envFrom, volumes and projected volumes, imagePullSecrets, and the service account token: the
+// kube-root-ca.crt ConfigMap and the legacy token Secrets of the service account, unless automounting is
+// disabled by the pod or the service account. ConfigMaps and Secrets are listed through Client.Metadata, so
+// that their data isn't read, and ErrMetadataClientRequired is returned if it isn't set.
+// An empty namespace indexes the whole cluster.
+func (client Client) GetConfigDependencies(namespace string) (*ConfigDependencyIndex, error) {
+	if client.Metadata == nil {
+		return nil, ErrMetadataClientRequired
+	}
+	// serviceAccounts is keyed by namespace/name.
+	serviceAccounts := map[string]serviceAccount{}
+	objects, err := client.listKind(schema.FromAPIVersionAndKind("v1", "ServiceAccount"), namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		automount, found, _ := unstructured.NestedBool(object.Object, "automountServiceAccountToken")
+		serviceAccounts[objectName(object.GetNamespace(), object.GetName())] = serviceAccount{noAutomount: found && !automount}
+	}
+	existing := map[ConfigReference]bool{}
+	for _, kind := range []string{"ConfigMap", "Secret"} {
+		objects, err := client.listKindMetadata(schema.FromAPIVersionAndKind("v1", kind), namespace, metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			reference := ConfigReference{Kind: kind, Namespace: object.GetNamespace(), Name: object.GetName()}
+			existing[reference] = true
+			if name, ok := object.GetAnnotations()[corev1.ServiceAccountNameKey]; ok && kind == "Secret" {
+				key := objectName(reference.Namespace, name)
+				account := serviceAccounts[key]
+				account.tokens = append(account.tokens, reference)
+				serviceAccounts[key] = account
+			}
+		}
+	}
+	workloads, err := client.getWorkloads(namespaceFilter(namespace), false)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &ConfigDependencyIndex{
+		ByWorkload: map[WorkloadID][]ConfigReference{},
+		ByObject:   map[ConfigReference][]WorkloadID{},
+	}
+	required := map[ConfigReference]bool{}
+	for _, workload := range workloads {
+		if workload.PodSpec == nil {
+			continue
+		}
+		references := configReferences(workload.TopController.GetNamespace(), workload.PodSpec, serviceAccounts)
+		for reference, optional := range references {
+			index.ByWorkload[workload.ID] = append(index.ByWorkload[workload.ID], reference)
+			index.ByObject[reference] = append(index.ByObject[reference], workload.ID)
+			if !optional {
+				required[reference] = true
+			}
+		}
+		sortConfigReferences(index.ByWorkload[workload.ID])
+	}
+	for reference, workloads := range index.ByObject {
+		sort.Slice(workloads, func(i, j int) bool {
+			return workloads[i].String() < workloads[j].String()
+		})
+		if !existing[reference] && required[reference] {
+			index.Missing = append(index.Missing, reference)
+		}
+	}
+	for reference := range existing {
+		if _, ok := index.ByObject[reference]; !ok {
+			index.Unreferenced = append(index.Unreferenced, reference)
+		}
+	}
+	sortConfigReferences(index.Missing)
+	sortConfigReferences(index.Unreferenced)
+	return index, nil
+}
+
+// configReferences returns the objects referenced by a pod spec, and whether every reference to each is optional.
+func configReferences(namespace string, podSpec *corev1.PodSpec, serviceAccounts map[string]serviceAccount) map[ConfigReference]bool {
+	references := map[ConfigReference]bool{}
+	add := func(kind, name string, optional *bool) {
+		if name == "" {
+			return
+		}
+		reference := ConfigReference{Kind: kind, Namespace: namespace, Name: name}
+		isOptional := optional != nil && *optional
+		if previous, ok := references[reference]; ok {
+			isOptional = isOptional && previous
+		}
+		references[reference] = isOptional
+	}
+
+	var containers []corev1.Container
+	containers = append(containers, podSpec.InitContainers...)
+	containers = append(containers, podSpec.Containers...)
+	for _, container := range podSpec.EphemeralContainers {
+		containers = append(containers, corev1.Container(container.EphemeralContainerCommon))
+	}
+	for _, container := range containers {
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				add("ConfigMap", ref.Name, ref.Optional)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				add("Secret", ref.Name, ref.Optional)
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if ref := envFrom.ConfigMapRef; ref != nil {
+				add("ConfigMap", ref.Name, ref.Optional)
+			}
+			if ref := envFrom.SecretRef; ref != nil {
+				add("Secret", ref.Name, ref.Optional)
+			}
+		}
+	}
+	for _, volume := range podSpec.Volumes {
+		if source := volume.ConfigMap; source != nil {
+			add("ConfigMap", source.Name, source.Optional)
+		}
+		if source := volume.Secret; source != nil {
+			add("Secret", source.SecretName, source.Optional)
+		}
+		if volume.Projected == nil {
+			continue
+		}
+		for _, source := range volume.Projected.Sources {
+			if source.ConfigMap != nil {
+				add("ConfigMap", source.ConfigMap.Name, source.ConfigMap.Optional)
+			}
+			if source.Secret != nil {
+				add("Secret", source.Secret.Name, source.Secret.Optional)
+			}
+		}
+	}
+	for _, pullSecret := range podSpec.ImagePullSecrets {
+		add("Secret", pullSecret.Name, nil)
+	}
+	name := podSpec.ServiceAccountName
+	if name == "" {
+		name = "default"
+	}
+	account := serviceAccounts[objectName(namespace, name)]
+	automount := !account.noAutomount
+	if podSpec.AutomountServiceAccountToken != nil {
+		// The pod's setting takes precedence over the service account's.
+		automount = *podSpec.AutomountServiceAccountToken
+	}
+	if automount {
+		add("ConfigMap", rootCACertConfigMap, nil)
+		for _, token := range account.tokens {
+			add(token.Kind, token.Name, nil)
+		}
+	}
+	return references
+}
+
+func sortConfigReferences(references []ConfigReference) {
+	sort.Slice(references, func(i, j int) bool {
+		return references[i].String() < references[j].String()
+	})
+}
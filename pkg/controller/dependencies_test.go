@@ -0,0 +1,144 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+var (
+	configMapsResource = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	secretsResource    = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+)
+
+func newDependencyClient(t *testing.T, objects ...runtime.Object) Client {
+	client := newFakeClient(t, []schema.GroupVersionKind{
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Version: "v1", Kind: "Pod"},
+		{Version: "v1", Kind: "ConfigMap"},
+		{Version: "v1", Kind: "Secret"},
+		{Version: "v1", Kind: "ServiceAccount"},
+	}, map[schema.GroupVersionResource]string{
+		deploymentsResource: "DeploymentList",
+		podsResource:        "PodList",
+		configMapsResource:  "ConfigMapList",
+		secretsResource:     "SecretList",
+		{Version: "v1", Resource: "serviceaccounts"}: "ServiceAccountList",
+	}, objects...)
+	client.Metadata = newMetadataClient(t, client, configMapsResource, secretsResource)
+	return client
+}
+
+func newConfigObject(kind, name string, annotations map[string]string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: map[string]any{}}
+	object.SetAPIVersion("v1")
+	object.SetKind(kind)
+	object.SetName(name)
+	object.SetNamespace("test")
+	object.SetAnnotations(annotations)
+	return object
+}
+
+// setPodSpecField sets a field of the pod template of a deployment.
+func setPodSpecField(t *testing.T, deployment *unstructured.Unstructured, value any, fields ...string) {
+	assert.NoError(t, unstructured.SetNestedField(deployment.Object, value, append([]string{"spec", "template", "spec"}, fields...)...))
+}
+
+func TestGetConfigDependencies(t *testing.T) {
+	web := newDeploymentWithVolumes("web", map[string]any{"name": "certs", "projected": map[string]any{"sources": []any{
+		map[string]any{"secret": map[string]any{"name": "tls"}},
+	}}})
+	setPodSpecField(t, web, "web-sa", "serviceAccountName")
+	setPodSpecField(t, web, []any{map[string]any{"name": "registry"}}, "imagePullSecrets")
+	setPodSpecField(t, web, []any{map[string]any{
+		"name": "app",
+		"env": []any{
+			map[string]any{"name": "PASSWORD", "valueFrom": map[string]any{"secretKeyRef": map[string]any{"name": "db-creds", "key": "password"}}},
+			map[string]any{"name": "FLAGS", "valueFrom": map[string]any{"configMapKeyRef": map[string]any{"name": "flags", "key": "flags", "optional": true}}},
+		},
+		"envFrom": []any{map[string]any{"configMapRef": map[string]any{"name": "web-config"}}},
+	}}, "containers")
+	batch := newDeploymentWithVolumes("batch",
+		map[string]any{"name": "creds", "secret": map[string]any{"secretName": "db-creds"}},
+		map[string]any{"name": "config", "configMap": map[string]any{"name": "missing-config"}},
+	)
+	setPodSpecField(t, batch, false, "automountServiceAccountToken")
+	// The service account of the worker disables automounting, and the worker doesn't override it.
+	worker := newDeploymentWithVolumes("worker", claimVolume("unrelated"))
+	setPodSpecField(t, worker, "locked", "serviceAccountName")
+	locked := newConfigObject("ServiceAccount", "locked", nil)
+	locked.Object["automountServiceAccountToken"] = false
+
+	client := newDependencyClient(t, web, batch, worker, locked,
+		newConfigObject("ServiceAccount", "web-sa", nil),
+		newConfigObject("ConfigMap", "web-config", nil),
+		newConfigObject("ConfigMap", "kube-root-ca.crt", nil),
+		newConfigObject("ConfigMap", "unused-config", nil),
+		newConfigObject("Secret", "db-creds", nil),
+		newConfigObject("Secret", "tls", nil),
+		newConfigObject("Secret", "registry", nil),
+		newConfigObject("Secret", "web-sa-token", map[string]string{"kubernetes.io/service-account.name": "web-sa"}),
+		newConfigObject("Secret", "default-token", map[string]string{"kubernetes.io/service-account.name": "default"}),
+		newConfigObject("Secret", "locked-token", map[string]string{"kubernetes.io/service-account.name": "locked"}),
+	)
+	dynamic := client.Dynamic.(*fake.FakeDynamicClient)
+	dynamic.ClearActions()
+	index, err := client.GetConfigDependencies("test")
+	assert.NoError(t, err)
+	for _, action := range dynamic.Actions() {
+		assert.NotEqual(t, "secrets", action.GetResource().Resource, "secrets must only be listed as metadata")
+	}
+
+	webID := WorkloadID{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "web"}
+	batchID := WorkloadID{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "batch"}
+	workerID := WorkloadID{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "worker"}
+	reference := func(kind, name string) ConfigReference {
+		return ConfigReference{Kind: kind, Namespace: "test", Name: name}
+	}
+	assert.Equal(t, []ConfigReference{
+		reference("ConfigMap", "flags"),
+		reference("ConfigMap", "kube-root-ca.crt"),
+		reference("ConfigMap", "web-config"),
+		reference("Secret", "db-creds"),
+		reference("Secret", "registry"),
+		reference("Secret", "tls"),
+		reference("Secret", "web-sa-token"),
+	}, index.ByWorkload[webID])
+	assert.Equal(t, []ConfigReference{
+		reference("ConfigMap", "missing-config"),
+		reference("Secret", "db-creds"),
+	}, index.ByWorkload[batchID])
+	assert.Empty(t, index.ByWorkload[workerID])
+	assert.Equal(t, []WorkloadID{batchID, webID}, index.ByObject[reference("Secret", "db-creds")])
+	assert.Equal(t, []WorkloadID{webID}, index.ByObject[reference("ConfigMap", "flags")])
+	// The optional flags ConfigMap is not reported as missing.
+	assert.Equal(t, []ConfigReference{reference("ConfigMap", "missing-config")}, index.Missing)
+	assert.Equal(t, []ConfigReference{
+		reference("ConfigMap", "unused-config"),
+		reference("Secret", "default-token"),
+		reference("Secret", "locked-token"),
+	}, index.Unreferenced)
+	assert.Equal(t, "Secret/test/db-creds", reference("Secret", "db-creds").String())
+
+	client.Metadata = nil
+	_, err = client.GetConfigDependencies("test")
+	assert.ErrorIs(t, err, ErrMetadataClientRequired)
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fairwindsops/controller-utils/pkg/log"
+)
+
+// DescendantOptions limits the objects returned by GetDescendants.
+type DescendantOptions struct {
+	// MaxDepth is the number of levels below the top controller that are returned, e.g. 1 for
+	// only the objects it owns directly. Zero means no limit.
+	MaxDepth int
+	// Kinds limits the result to objects of these kinds. The children of objects of other kinds
+	// are still returned, and take their place in the tree. Empty means every kind.
+	Kinds []schema.GroupKind
+}
+
+// Descendant is an object owned by a top controller, directly or through other objects, along with
+// the objects it owns in turn.
+type Descendant struct {
+	Object unstructured.Unstructured
+	// Depth is the number of owner references between the top controller and the object.
+	Depth    int
+	Children []Descendant
+}
+
+// GetDescendants returns the tree of objects owned by a top controller, such as the ReplicaSets and Pods of a
+// Deployment, including old ReplicaSets without pods. Owned objects are matched by the UID in their owner
+// references, so the top controller must have been read from the API server. Pods and objects of every kind
+// in the registry are listed in the namespace of the top controller; kinds that can't be listed are skipped.
+// Like GetTopController, objectCache can be shared between calls: kinds it records as listed in that namespace
+// aren't listed again, and the kinds that are listed are added to it.
+// When Client.Metadata is set, descendants other than pods only hold their metadata.
+func (client Client) GetDescendants(ctx context.Context, topController unstructured.Unstructured, objectCache map[string]unstructured.Unstructured, options DescendantOptions) (*Descendant, error) {
+	client.Context = ctx
+	namespace := topController.GetNamespace()
+	cache := newObjectCache(objectCache)
+	for _, kind := range client.registry().Kinds() {
+		if cache.wasListed(kind.GroupVersionKind.GroupKind(), namespace) {
+			continue
+		}
+		apiVersion, kindName := kind.GroupVersionKind.ToAPIVersionAndKind()
+		err := client.cacheAllObjectsOfKind(apiVersion, kindName, namespace, cache, false, metav1.ListOptions{})
+		if ctxErr := client.context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			log.GetLogger().V(3).Info("Unable to list objects of kind " + kindName)
+		}
+	}
+	pods, err := client.getAllPods(namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	owned := map[types.UID][]unstructured.Unstructured{}
+	for _, object := range append(cache.list(), pods...) {
+		for _, owner := range object.GetOwnerReferences() {
+			if owner.UID != "" {
+				owned[owner.UID] = append(owned[owner.UID], object)
+			}
+		}
+	}
+	tree := descendantTree{owned: owned, options: options, seen: map[types.UID]bool{}}
+	return &Descendant{
+		Object:   topController,
+		Children: tree.children(topController, 0),
+	}, nil
+}
+
+// descendantTree builds the Descendant tree from the objects grouped by the UIDs of their owners.
+type descendantTree struct {
+	owned   map[types.UID][]unstructured.Unstructured
+	options DescendantOptions
+	// seen guards against owner reference cycles.
+	seen map[types.UID]bool
+}
+
+func (tree descendantTree) children(object unstructured.Unstructured, depth int) []Descendant {
+	uid := object.GetUID()
+	if uid == "" || tree.seen[uid] || (tree.options.MaxDepth > 0 && depth >= tree.options.MaxDepth) {
+		return nil
+	}
+	tree.seen[uid] = true
+	defer delete(tree.seen, uid)
+
+	owned := tree.owned[uid]
+	sort.Slice(owned, func(i, j int) bool {
+		if owned[i].GetKind() != owned[j].GetKind() {
+			return owned[i].GetKind() < owned[j].GetKind()
+		}
+		return owned[i].GetName() < owned[j].GetName()
+	})
+	var children []Descendant
+	for _, child := range owned {
+		grandchildren := tree.children(child, depth+1)
+		if !tree.includes(child) {
+			children = append(children, grandchildren...)
+			continue
+		}
+		children = append(children, Descendant{
+			Object:   child,
+			Depth:    depth + 1,
+			Children: grandchildren,
+		})
+	}
+	return children
+}
+
+func (tree descendantTree) includes(object unstructured.Unstructured) bool {
+	if len(tree.options.Kinds) == 0 {
+		return true
+	}
+	groupKind := object.GroupVersionKind().GroupKind()
+	for _, kind := range tree.options.Kinds {
+		if kind == groupKind {
+			return true
+		}
+	}
+	return false
+}
+
+// Flatten returns the descendants below this object, parents before their children.
+func (descendant Descendant) Flatten() []unstructured.Unstructured {
+	var objects []unstructured.Unstructured
+	for _, child := range descendant.Children {
+		objects = append(objects, child.Object)
+		objects = append(objects, child.Flatten()...)
+	}
+	return objects
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func newOwnedObject(apiVersion, kind, name string, uid types.UID, owner *unstructured.Unstructured) unstructured.Unstructured {
+	object := unstructured.Unstructured{}
+	object.SetAPIVersion(apiVersion)
+	object.SetKind(kind)
+	object.SetName(name)
+	object.SetNamespace("test")
+	object.SetUID(uid)
+	if owner != nil {
+		object.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: owner.GetAPIVersion(), Kind: owner.GetKind(), Name: owner.GetName(), UID: owner.GetUID()}})
+	}
+	return object
+}
+
+func descendantNames(descendants []Descendant) []string {
+	names := []string{}
+	for _, descendant := range descendants {
+		names = append(names, descendant.Object.GetKind()+"/"+descendant.Object.GetName())
+	}
+	return names
+}
+
+func TestGetDescendants(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	web := newOwnedObject("apps/v1", "Deployment", "web", "web-uid", nil)
+	current := newOwnedObject("apps/v1", "ReplicaSet", "web-2", "web-2-uid", &web)
+	old := newOwnedObject("apps/v1", "ReplicaSet", "web-1", "web-1-uid", &web)
+	recreated := newOwnedObject("apps/v1", "Deployment", "web", "recreated-uid", nil)
+	stale := newOwnedObject("apps/v1", "ReplicaSet", "web-0", "web-0-uid", &recreated)
+	createObject(t, client, deploymentsResource, web)
+	createObject(t, client, replicaSetsResource, current)
+	createObject(t, client, replicaSetsResource, old)
+	createObject(t, client, replicaSetsResource, stale)
+	createObject(t, client, podsResource, newOwnedObject("v1", "Pod", "web-2-b", "b", &current))
+	createObject(t, client, podsResource, newOwnedObject("v1", "Pod", "web-2-a", "a", &current))
+
+	tree, err := client.GetDescendants(context.TODO(), web, nil, DescendantOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "web", tree.Object.GetName())
+	assert.Equal(t, []string{"ReplicaSet/web-1", "ReplicaSet/web-2"}, descendantNames(tree.Children))
+	assert.Empty(t, tree.Children[0].Children)
+	assert.Equal(t, []string{"Pod/web-2-a", "Pod/web-2-b"}, descendantNames(tree.Children[1].Children))
+	assert.Equal(t, 2, tree.Children[1].Children[0].Depth)
+	assert.Len(t, tree.Flatten(), 4)
+
+	tree, err = client.GetDescendants(context.TODO(), web, nil, DescendantOptions{MaxDepth: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ReplicaSet/web-1", "ReplicaSet/web-2"}, descendantNames(tree.Children))
+	assert.Empty(t, tree.Children[1].Children)
+
+	tree, err = client.GetDescendants(context.TODO(), web, nil, DescendantOptions{Kinds: []schema.GroupKind{{Kind: "Pod"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Pod/web-2-a", "Pod/web-2-b"}, descendantNames(tree.Children))
+	assert.Equal(t, 2, tree.Children[0].Depth)
+
+	// Kinds already in the object cache aren't listed again.
+	objectCache := map[string]unstructured.Unstructured{}
+	_, err = client.GetTopController(tree.Flatten()[0], objectCache)
+	assert.NoError(t, err)
+	dynamic := client.Dynamic.(*dynamicfake.FakeDynamicClient)
+	dynamic.ClearActions()
+	tree, err = client.GetDescendants(context.TODO(), web, objectCache, DescendantOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, tree.Flatten(), 4)
+	for _, action := range dynamic.Actions() {
+		assert.NotContains(t, []string{"deployments", "replicasets"}, action.GetResource().Resource)
+	}
+
+	// Objects that were put in the cache one at a time don't stop their kind from being listed.
+	partial := map[string]unstructured.Unstructured{getControllerKey(old): old}
+	tree, err = client.GetDescendants(context.TODO(), web, partial, DescendantOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ReplicaSet/web-1", "ReplicaSet/web-2"}, descendantNames(tree.Children))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.GetDescendants(ctx, web, nil, DescendantOptions{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
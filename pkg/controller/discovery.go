@@ -0,0 +1,130 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fairwindsops/controller-utils/pkg/log"
+)
+
+// DiscoveryFailure is a kind that couldn't be listed, or an object that couldn't be resolved or decoded.
+type DiscoveryFailure struct {
+	GroupVersionKind schema.GroupVersionKind
+	// Namespace is the namespace that was listed, or the namespace of the object. It is empty for cluster wide lists.
+	Namespace string
+	// Name is the object that failed. It is empty when a whole kind failed to list.
+	Name string
+	// Err is the cause, usually one of the error types in this package, e.g. a *ForbiddenError.
+	Err error
+}
+
+func (failure DiscoveryFailure) Error() string {
+	if failure.Name == "" {
+		return failure.Err.Error()
+	}
+	return fmt.Sprintf("%s %s: %v", failure.GroupVersionKind.Kind, objectName(failure.Namespace, failure.Name), failure.Err)
+}
+
+// Unwrap returns the cause.
+func (failure DiscoveryFailure) Unwrap() error { return failure.Err }
+
+// DiscoveryResult holds every workload that could be built, and what went wrong on the way.
+type DiscoveryResult struct {
+	Workloads []Workload
+	// Failures lists the kinds that couldn't be listed and the objects that couldn't be resolved or decoded.
+	Failures []DiscoveryFailure
+	// Lists is the number of kind and namespace pairs that were listed up front, pods and nodes included.
+	// Failures without a Name count against it.
+	Lists int
+	// Orphans reports the pods and controllers whose owners couldn't be found, including owned controllers without pods.
+	Orphans *OrphanReport
+
+	// legacyErr is the first failure that used to abort the whole discovery.
+	legacyErr error
+}
+
+// Err joins all failures into one error, or returns nil if there were none.
+func (result *DiscoveryResult) Err() error {
+	errs := make([]error, 0, len(result.Failures))
+	for _, failure := range result.Failures {
+		errs = append(errs, failure)
+	}
+	return errors.Join(errs...)
+}
+
+// DiscoverWorkloads is like GetFilteredTopControllersSummary and GetFilteredTopControllersWithPods, but
+// it returns the workloads that could be built along with the failures, instead of stopping at some
+// failures and logging others. With Client.Strict it returns the first failure instead.
+func (client Client) DiscoverWorkloads(filter Filter, includePods bool) (*DiscoveryResult, error) {
+	result, err := client.getAllTopControllers(filter, includePods)
+	return result, err
+}
+
+// discoveryFailures collects failures from concurrent list calls.
+type discoveryFailures struct {
+	mu       sync.Mutex
+	strict   bool
+	failures []DiscoveryFailure
+	lists    int
+	// legacyErr is the first failure that used to abort the whole discovery, see DiscoveryResult.
+	legacyErr error
+}
+
+// listed records a list call, and its failure if err is set. legacy marks failures that used to abort the whole discovery.
+func (collector *discoveryFailures) listed(fqKind schema.GroupVersionKind, namespace string, err error, legacy bool) {
+	collector.mu.Lock()
+	collector.lists++
+	collector.mu.Unlock()
+	if err != nil {
+		collector.add(DiscoveryFailure{GroupVersionKind: fqKind, Namespace: namespace, Err: err}, legacy)
+	}
+}
+
+// add records a failure. legacy marks failures that used to abort the whole discovery.
+func (collector *discoveryFailures) add(failure DiscoveryFailure, legacy bool) {
+	log.GetLogger().V(1).Info("Discovery failure", "kind", failure.GroupVersionKind.Kind, "namespace", failure.Namespace, "name", failure.Name, "error", failure.Err.Error())
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.failures = append(collector.failures, failure)
+	if legacy && collector.legacyErr == nil {
+		collector.legacyErr = failure.Err
+	}
+}
+
+// stopped returns the first failure in strict mode, so that discovery can stop.
+func (collector *discoveryFailures) stopped() error {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.strict && len(collector.failures) > 0 {
+		return collector.failures[0]
+	}
+	return nil
+}
+
+func (collector *discoveryFailures) result(workloads []Workload) *DiscoveryResult {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	return &DiscoveryResult{
+		Workloads: workloads,
+		Failures:  collector.failures,
+		Lists:     collector.lists,
+		legacyErr: collector.legacyErr,
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiscoverWorkloads(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	result, err := client.DiscoverWorkloads(Filter{}, false)
+	assert.NoError(t, err)
+	assert.Len(t, result.Workloads, 3)
+	// Six top level kinds and the pods, in one cluster wide list each.
+	assert.Equal(t, 7, result.Lists)
+	unmapped := 0
+	for _, failure := range result.Failures {
+		if failure.Name == "" {
+			assert.ErrorIs(t, failure, ErrMappingNotFound)
+			unmapped++
+			continue
+		}
+		assert.Equal(t, "poddy-bad", failure.Name)
+		assert.Equal(t, "test2", failure.Namespace)
+		assert.ErrorIs(t, failure, ErrOwnerNotFound)
+	}
+	// CronJobs, Jobs, DaemonSets and StatefulSets aren't in the RESTMapper.
+	assert.Equal(t, 4, unmapped)
+	assert.Len(t, result.Failures, 5)
+	assert.ErrorIs(t, result.Err(), ErrOwnerNotFound)
+
+	client.Strict = true
+	_, err = client.DiscoverWorkloads(Filter{}, false)
+	assert.ErrorIs(t, err, ErrMappingNotFound)
+	_, err = client.GetAllTopControllersSummary("")
+	assert.ErrorIs(t, err, ErrMappingNotFound)
+}
+
+func TestDiscoverWorkloadsForbidden(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	client.Dynamic.(*fake.FakeDynamicClient).PrependReactor("list", "deployments", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"}, "", errors.New("no RBAC"))
+	})
+	result, err := client.DiscoverWorkloads(Filter{Namespaces: []string{"test"}}, false)
+	assert.NoError(t, err)
+	forbidden := 0
+	for _, failure := range result.Failures {
+		if errors.Is(failure, ErrForbidden) {
+			forbidden++
+		}
+	}
+	// The up front list, and the lookup of the deployment that owns the replica set.
+	assert.Equal(t, 2, forbidden)
+	// The replica set is returned as the top controller, since its owner couldn't be looked up.
+	assert.NotNil(t, findWorkload(result.Workloads, "rs"))
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.NotNil(t, findWorkload(workloads, "rs"))
+}
+
+func TestDiscoverWorkloadsMalformed(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	malformed := unstructured.Unstructured{Object: readFile(t, "./testdata/deployment.json")}
+	malformed.SetName("malformed")
+	malformed.SetNamespace("test")
+	assert.NoError(t, unstructured.SetNestedField(malformed.Object, "not a list", "spec", "template", "spec", "containers"))
+	_, err := client.Dynamic.Resource(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}).Namespace("test").Create(context.TODO(), &malformed, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	result, err := client.DiscoverWorkloads(Filter{Namespaces: []string{"test"}}, false)
+	assert.NoError(t, err)
+	workload := findWorkload(result.Workloads, "malformed")
+	assert.NotNil(t, workload)
+	assert.Nil(t, workload.PodSpec)
+	assert.NotNil(t, findWorkload(result.Workloads, "dep"))
+	var found bool
+	for _, failure := range result.Failures {
+		if failure.Name == "malformed" {
+			found = true
+			assert.ErrorIs(t, failure, ErrMalformedObject)
+		}
+	}
+	assert.True(t, found)
+
+	_, err = client.GetAllTopControllersSummary("test")
+	assert.ErrorIs(t, err, ErrMalformedObject)
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Sentinel errors that the error types below match with errors.Is.
+var (
+	ErrOwnerNotFound   = errors.New("owner not found")
+	ErrMappingNotFound = errors.New("no mapping found for kind")
+	ErrForbidden       = errors.New("forbidden")
+	ErrListFailed      = errors.New("list failed")
+	ErrMalformedObject = errors.New("malformed object")
+	ErrOwnerCycle      = errors.New("owner references form a cycle")
+)
+
+// OwnerNotFoundError is returned when an owner reference points to an object that doesn't exist.
+// GroupVersionKind, Namespace and Name describe the missing owner. Err is set when the owner's kind
+// couldn't be listed, e.g. to a *MappingNotFoundError.
+type OwnerNotFoundError struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	// Owned is the object holding the owner reference.
+	Owned string
+	Err   error
+}
+
+func (err *OwnerNotFoundError) Error() string {
+	message := fmt.Sprintf("owner %s %s of %s not found", err.GroupVersionKind.Kind, objectName(err.Namespace, err.Name), err.Owned)
+	if err.Err != nil {
+		message += ": " + err.Err.Error()
+	}
+	return message
+}
+
+// Is matches ErrOwnerNotFound.
+func (err *OwnerNotFoundError) Is(target error) bool { return target == ErrOwnerNotFound }
+
+// Unwrap returns the underlying cause.
+func (err *OwnerNotFoundError) Unwrap() error { return err.Err }
+
+// MappingNotFoundError is returned when the RESTMapper doesn't know a kind, usually because it isn't served.
+type MappingNotFoundError struct {
+	GroupVersionKind schema.GroupVersionKind
+	Err              error
+}
+
+func (err *MappingNotFoundError) Error() string {
+	return fmt.Sprintf("no mapping found for %s: %v", err.GroupVersionKind.String(), err.Err)
+}
+
+// Is matches ErrMappingNotFound.
+func (err *MappingNotFoundError) Is(target error) bool { return target == ErrMappingNotFound }
+
+// Unwrap returns the underlying cause, so that meta.IsNoMatchError keeps working.
+func (err *MappingNotFoundError) Unwrap() error { return err.Err }
+
+// ForbiddenError is returned when RBAC doesn't allow listing a kind. Namespace is empty for cluster wide lists.
+type ForbiddenError struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Err              error
+}
+
+func (err *ForbiddenError) Error() string {
+	return fmt.Sprintf("forbidden to list %s%s: %v", err.GroupVersionKind.Kind, inNamespace(err.Namespace), err.Err)
+}
+
+// Is matches ErrForbidden.
+func (err *ForbiddenError) Is(target error) bool { return target == ErrForbidden }
+
+// Unwrap returns the underlying cause.
+func (err *ForbiddenError) Unwrap() error { return err.Err }
+
+// ListFailedError is returned when listing a kind fails for any other reason than RBAC.
+type ListFailedError struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Err              error
+}
+
+func (err *ListFailedError) Error() string {
+	return fmt.Sprintf("failed to list %s%s: %v", err.GroupVersionKind.Kind, inNamespace(err.Namespace), err.Err)
+}
+
+// Is matches ErrListFailed.
+func (err *ListFailedError) Is(target error) bool { return target == ErrListFailed }
+
+// Unwrap returns the underlying cause.
+func (err *ListFailedError) Unwrap() error { return err.Err }
+
+// MalformedObjectError is returned when an object can't be decoded, e.g. a pod template that isn't a valid PodSpec.
+type MalformedObjectError struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	Err              error
+}
+
+func (err *MalformedObjectError) Error() string {
+	return fmt.Sprintf("malformed %s %s: %v", err.GroupVersionKind.Kind, objectName(err.Namespace, err.Name), err.Err)
+}
+
+// Is matches ErrMalformedObject.
+func (err *MalformedObjectError) Is(target error) bool { return target == ErrMalformedObject }
+
+// Unwrap returns the underlying cause.
+func (err *MalformedObjectError) Unwrap() error { return err.Err }
+
+func newMalformedObjectError(obj map[string]any, err error) error {
+	object := unstructured.Unstructured{Object: obj}
+	return &MalformedObjectError{
+		GroupVersionKind: object.GroupVersionKind(),
+		Namespace:        object.GetNamespace(),
+		Name:             object.GetName(),
+		Err:              err,
+	}
+}
+
+func newOwnerNotFoundError(owned unstructured.Unstructured, owner metav1.OwnerReference, err error) error {
+	return &OwnerNotFoundError{
+		GroupVersionKind: schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind),
+		Namespace:        owned.GetNamespace(),
+		Name:             owner.Name,
+		Owned:            getControllerKey(owned),
+		Err:              err,
+	}
+}
+
+// errUIDMismatch explains that an object with the owner's name exists, but isn't the owner.
+func errUIDMismatch(expected, found types.UID) error {
+	return fmt.Errorf("found UID %s instead of %s", found, expected)
+}
+
+func objectName(namespace, name string) string {
+	return strings.TrimPrefix(namespace+"/"+name, "/")
+}
+
+func inNamespace(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+	return " in namespace " + namespace
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestOwnerNotFoundError(t *testing.T) {
+	client, _, _, _, pod2 := setupFakeData(t)
+	_, err := client.GetTopController(pod2, nil)
+	var notFound *OwnerNotFoundError
+	assert.ErrorAs(t, err, &notFound)
+	assert.Equal(t, schema.GroupVersionKind{Group: "core", Version: "v1", Kind: "ReplicaNotASet"}, notFound.GroupVersionKind)
+	assert.Equal(t, "test2", notFound.Namespace)
+	assert.Equal(t, "rs", notFound.Name)
+	assert.Equal(t, "Pod/test2/poddy-bad", notFound.Owned)
+	assert.ErrorIs(t, err, ErrOwnerNotFound)
+	assert.ErrorIs(t, err, ErrMappingNotFound)
+	assert.True(t, meta.IsNoMatchError(err))
+
+	pod := newPodWithOwners("adopted", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "missing"})
+	_, err = client.GetTopController(pod, nil)
+	assert.ErrorIs(t, err, ErrOwnerNotFound)
+	assert.NotErrorIs(t, err, ErrMappingNotFound)
+	assert.EqualError(t, err, "owner ReplicaSet test/missing of Pod/test/adopted not found")
+}
+
+func TestListErrors(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	dynamic := client.Dynamic.(*fake.FakeDynamicClient)
+	dynamic.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", errors.New("no RBAC"))
+	})
+	_, err := client.GetAllTopControllersSummary("test")
+	var forbidden *ForbiddenError
+	assert.ErrorAs(t, err, &forbidden)
+	assert.Equal(t, "Pod", forbidden.GroupVersionKind.Kind)
+	assert.Equal(t, "test", forbidden.Namespace)
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.True(t, apierrors.IsForbidden(err))
+
+	client, _, _, _, _ = setupFakeData(t)
+	dynamic = client.Dynamic.(*fake.FakeDynamicClient)
+	dynamic.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("try again")
+	})
+	_, err = client.GetAllTopControllersSummary("")
+	var listFailed *ListFailedError
+	assert.ErrorAs(t, err, &listFailed)
+	assert.Equal(t, "", listFailed.Namespace)
+	assert.ErrorIs(t, err, ErrListFailed)
+	assert.NotErrorIs(t, err, ErrForbidden)
+
+	client.RESTMapper = meta.NewDefaultRESTMapper(nil)
+	_, err = client.GetAllPersistentVolumeClaims("test")
+	var mappingNotFound *MappingNotFoundError
+	assert.ErrorAs(t, err, &mappingNotFound)
+	assert.Equal(t, "PersistentVolumeClaim", mappingNotFound.GroupVersionKind.Kind)
+}
+
+func TestMalformedObjectError(t *testing.T) {
+	deployment := readFile(t, "./testdata/deployment.json")
+	template := deployment["spec"].(map[string]any)["template"].(map[string]any)
+	template["spec"].(map[string]any)["containers"] = "not a list"
+	_, _, err := GetPodMetadataAndSpec(deployment)
+	var malformed *MalformedObjectError
+	assert.ErrorAs(t, err, &malformed)
+	assert.Equal(t, "Deployment", malformed.GroupVersionKind.Kind)
+	assert.Equal(t, "dep", malformed.Name)
+	assert.ErrorIs(t, err, ErrMalformedObject)
+}
@@ -0,0 +1,151 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// WorkloadEventType describes what happened to a workload.
+type WorkloadEventType string
+
+const (
+	// WorkloadAdded is emitted when a workload first appears.
+	WorkloadAdded WorkloadEventType = "Added"
+	// WorkloadUpdated is emitted when the top controller, pod spec or pod counts of a workload change.
+	WorkloadUpdated WorkloadEventType = "Updated"
+	// WorkloadRemoved is emitted when a workload disappears.
+	WorkloadRemoved WorkloadEventType = "Removed"
+)
+
+// WorkloadEvent describes a change to a workload. Old is nil for WorkloadAdded events
+// and New is nil for WorkloadRemoved events. Neither includes individual pods.
+type WorkloadEvent struct {
+	Type WorkloadEventType
+	Old  *Workload
+	New  *Workload
+}
+
+// WorkloadEventHandler is called for every WorkloadEvent. Handlers are called one at a time
+// and must not add or remove handlers themselves.
+type WorkloadEventHandler func(WorkloadEvent)
+
+type registeredHandler struct {
+	handle WorkloadEventHandler
+}
+
+// AddEventHandler registers a handler for workload events. Events for a single workload are
+// debounced for EventDebounce, so a burst of pod changes results in a single update.
+// The returned function removes the handler.
+func (c *WorkloadCache) AddEventHandler(handler WorkloadEventHandler) func() {
+	registered := &registeredHandler{handle: handler}
+	c.handlersMu.Lock()
+	c.handlers = append(c.handlers, registered)
+	c.handlersMu.Unlock()
+	return func() {
+		c.handlersMu.Lock()
+		defer c.handlersMu.Unlock()
+		for idx, existing := range c.handlers {
+			if existing == registered {
+				c.handlers = append(c.handlers[:idx], c.handlers[idx+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel that receives workload events, buffered to the given size.
+// Sends block once the buffer is full, so the channel should be drained promptly.
+// The returned function unsubscribes and closes the channel.
+func (c *WorkloadCache) Subscribe(buffer int) (<-chan WorkloadEvent, func()) {
+	events := make(chan WorkloadEvent, buffer)
+	done := make(chan struct{})
+	remove := c.AddEventHandler(func(event WorkloadEvent) {
+		select {
+		case events <- event:
+		case <-done:
+		}
+	})
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			close(done)
+			remove()
+			close(events)
+		})
+	}
+}
+
+// markDirty records the current state of a workload before it is changed and schedules
+// an event for it. Must be called with mu held.
+func (c *WorkloadCache) markDirty(key WorkloadID) {
+	if _, ok := c.pending[key]; ok {
+		return
+	}
+	var old *Workload
+	if existing, ok := c.workloads[key]; ok {
+		workload := existing.toWorkload(c.client.registry(), false)
+		old = &workload
+	}
+	c.pending[key] = old
+	time.AfterFunc(c.EventDebounce, func() {
+		c.flush(key)
+	})
+}
+
+func (c *WorkloadCache) flush(key WorkloadID) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+
+	c.mu.Lock()
+	old, ok := c.pending[key]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	delete(c.pending, key)
+	var current *Workload
+	if existing, ok := c.workloads[key]; ok {
+		workload := existing.toWorkload(c.client.registry(), false)
+		current = &workload
+	}
+	c.mu.Unlock()
+
+	event := WorkloadEvent{Old: old, New: current}
+	switch {
+	case old == nil && current == nil:
+		return
+	case old == nil:
+		event.Type = WorkloadAdded
+	case current == nil:
+		event.Type = WorkloadRemoved
+	case workloadChanged(old, current):
+		event.Type = WorkloadUpdated
+	default:
+		return
+	}
+	for _, handler := range c.handlers {
+		handler.handle(event)
+	}
+}
+
+func workloadChanged(old, current *Workload) bool {
+	return old.PodCount != current.PodCount ||
+		old.RunningPodCount != current.RunningPodCount ||
+		!reflect.DeepEqual(old.PodSpec, current.PodSpec) ||
+		!reflect.DeepEqual(old.TopController.Object, current.TopController.Object)
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func receiveEvents(events <-chan WorkloadEvent, wait time.Duration) []WorkloadEvent {
+	received := []WorkloadEvent{}
+	for {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(wait):
+			return received
+		}
+	}
+}
+
+func TestWorkloadEvents(t *testing.T) {
+	client, pod, _, _, _ := setupFakeData(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	workloadCache, err := NewWorkloadCache(client, "test", 0)
+	assert.NoError(t, err)
+	workloadCache.EventDebounce = 100 * time.Millisecond
+	events, unsubscribe := workloadCache.Subscribe(10)
+	defer unsubscribe()
+
+	workloadCache.Start(ctx)
+	assert.True(t, workloadCache.WaitForCacheSync(ctx))
+	received := receiveEvents(events, 300*time.Millisecond)
+	assert.Len(t, received, 2)
+	for _, event := range received {
+		assert.Equal(t, WorkloadAdded, event.Type)
+		assert.Nil(t, event.Old)
+	}
+
+	pods := client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("test")
+	for i := 0; i < 5; i++ {
+		newPod := pod.DeepCopy()
+		newPod.SetName(fmt.Sprintf("poddy-%d", i))
+		_, err = pods.Create(ctx, newPod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	received = receiveEvents(events, 300*time.Millisecond)
+	assert.Len(t, received, 1)
+	assert.Equal(t, WorkloadUpdated, received[0].Type)
+	assert.Equal(t, 1, received[0].Old.PodCount)
+	assert.Equal(t, 6, received[0].New.PodCount)
+
+	deployments := client.Dynamic.Resource(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}).Namespace("test")
+	assert.NoError(t, deployments.Delete(ctx, "dep-no-pods", metav1.DeleteOptions{}))
+	received = receiveEvents(events, 300*time.Millisecond)
+	assert.Len(t, received, 1)
+	assert.Equal(t, WorkloadRemoved, received[0].Type)
+	assert.Equal(t, "dep-no-pods", received[0].Old.TopController.GetName())
+	assert.Nil(t, received[0].New)
+}
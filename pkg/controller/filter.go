@@ -0,0 +1,137 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"github.com/samber/lo"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Filter limits which workloads are discovered. Selectors use the same syntax as kubectl and are
+// sent to the API server with every list call.
+//
+// A workload is returned only if its top level controller matches LabelSelector, FieldSelector and the
+// namespace lists. Pods without a controller are their own top level controller. Pods that don't match
+// PodLabelSelector or PodFieldSelector are left out of the pod counts, so a matching workload whose pods
+// were all filtered out is returned with no pods, the same as a controller that has no pods at all.
+type Filter struct {
+	// LabelSelector selects top level controllers, e.g. "app.kubernetes.io/managed-by=helm".
+	LabelSelector string
+	// FieldSelector selects top level controllers. Most kinds only support metadata.name and metadata.namespace.
+	FieldSelector string
+	// PodLabelSelector selects the pods that are counted for each workload.
+	PodLabelSelector string
+	// PodFieldSelector selects the pods that are counted for each workload, e.g. "status.phase=Running".
+	PodFieldSelector string
+	// Namespaces limits discovery to these namespaces. All namespaces are used if it is empty.
+	Namespaces []string
+	// ExcludedNamespaces are never included, even if they are listed in Namespaces.
+	ExcludedNamespaces []string
+}
+
+type compiledFilter struct {
+	filter        Filter
+	labelSelector labels.Selector
+	fieldSelector fields.Selector
+	excluded      fields.Selector
+}
+
+func namespaceFilter(namespace string) Filter {
+	if namespace == "" {
+		return Filter{}
+	}
+	return Filter{Namespaces: []string{namespace}}
+}
+
+func (filter Filter) compile() (compiledFilter, error) {
+	compiled := compiledFilter{filter: filter}
+	var err error
+	compiled.labelSelector, err = labels.Parse(filter.LabelSelector)
+	if err != nil {
+		return compiled, err
+	}
+	compiled.fieldSelector, err = fields.ParseSelector(filter.FieldSelector)
+	if err != nil {
+		return compiled, err
+	}
+	if _, err = labels.Parse(filter.PodLabelSelector); err != nil {
+		return compiled, err
+	}
+	if _, err = fields.ParseSelector(filter.PodFieldSelector); err != nil {
+		return compiled, err
+	}
+	excluded := make([]fields.Selector, 0, len(filter.ExcludedNamespaces))
+	for _, namespace := range filter.ExcludedNamespaces {
+		excluded = append(excluded, fields.OneTermNotEqualSelector("metadata.namespace", namespace))
+	}
+	compiled.excluded = fields.AndSelectors(excluded...)
+	return compiled, nil
+}
+
+// namespaces returns the namespaces that need to be listed, where an empty string means all namespaces.
+func (compiled compiledFilter) namespaces() []string {
+	if len(compiled.filter.Namespaces) == 0 {
+		return []string{""}
+	}
+	return lo.Uniq(lo.Filter(compiled.filter.Namespaces, func(namespace string, _ int) bool {
+		return compiled.matchesNamespace(namespace)
+	}))
+}
+
+func (compiled compiledFilter) controllerListOptions() metav1.ListOptions {
+	return compiled.listOptions(compiled.filter.LabelSelector, compiled.filter.FieldSelector)
+}
+
+func (compiled compiledFilter) podListOptions() metav1.ListOptions {
+	return compiled.listOptions(compiled.filter.PodLabelSelector, compiled.filter.PodFieldSelector)
+}
+
+func (compiled compiledFilter) listOptions(labelSelector, fieldSelector string) metav1.ListOptions {
+	selectors := []fields.Selector{compiled.excluded}
+	if fieldSelector != "" {
+		// Already validated in compile.
+		selectors = append(selectors, fields.ParseSelectorOrDie(fieldSelector))
+	}
+	return metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: fields.AndSelectors(selectors...).String(),
+	}
+}
+
+func (compiled compiledFilter) matchesNamespace(namespace string) bool {
+	if lo.Contains(compiled.filter.ExcludedNamespaces, namespace) {
+		return false
+	}
+	return len(compiled.filter.Namespaces) == 0 || lo.Contains(compiled.filter.Namespaces, namespace)
+}
+
+// matchesController checks a top level controller that was found through the owner walk,
+// and so was not listed with the filter's selectors. Field selectors can only be checked
+// against the object's name and namespace here.
+func (compiled compiledFilter) matchesController(controller unstructured.Unstructured) bool {
+	if !compiled.matchesNamespace(controller.GetNamespace()) {
+		return false
+	}
+	if !compiled.labelSelector.Matches(labels.Set(controller.GetLabels())) {
+		return false
+	}
+	return compiled.fieldSelector.Matches(fields.Set{
+		"metadata.name":      controller.GetName(),
+		"metadata.namespace": controller.GetNamespace(),
+	})
+}
@@ -0,0 +1,246 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fairwindsops/controller-utils/pkg/log"
+)
+
+// OwnerGraphNodeType describes the role of an object in an OwnerGraph.
+type OwnerGraphNodeType string
+
+const (
+	// OwnerGraphTopController is an object without owners, other than a pod.
+	OwnerGraphTopController OwnerGraphNodeType = "TopController"
+	// OwnerGraphIntermediate is an owned object other than a pod, such as a ReplicaSet owned by a Deployment.
+	OwnerGraphIntermediate OwnerGraphNodeType = "Intermediate"
+	// OwnerGraphPod is a pod.
+	OwnerGraphPod OwnerGraphNodeType = "Pod"
+	// OwnerGraphUnresolved is an owner reference that points to an object that could not be found.
+	OwnerGraphUnresolved OwnerGraphNodeType = "Unresolved"
+	// OwnerGraphExternal is an owner that is not looked up, such as the Node that owns a static pod.
+	OwnerGraphExternal OwnerGraphNodeType = "External"
+)
+
+// OwnerGraphNode is an object in an OwnerGraph. Unresolved and external nodes only have
+// the fields that are available from the owner reference.
+type OwnerGraphNode struct {
+	// ID is the WorkloadID of the object formatted as a string, e.g. Deployment.apps/default/web@<uid>.
+	ID         string             `json:"id"`
+	Type       OwnerGraphNodeType `json:"type"`
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Namespace  string             `json:"namespace,omitempty"`
+	Name       string             `json:"name"`
+	UID        types.UID          `json:"uid,omitempty"`
+}
+
+// OwnerGraphEdge is an owner reference, pointing from the owner to the object it owns.
+type OwnerGraphEdge struct {
+	Owner string `json:"owner"`
+	Owned string `json:"owned"`
+	// Controller is set when the owner reference is marked as the controller.
+	Controller bool `json:"controller"`
+}
+
+// OwnerGraph is the directed graph of owner references between objects.
+type OwnerGraph struct {
+	Nodes []OwnerGraphNode `json:"nodes"`
+	Edges []OwnerGraphEdge `json:"edges"`
+}
+
+// GetOwnerGraph builds the owner graph of every pod and every object of a registered kind in the namespace,
+// or in the whole cluster if it is empty.
+func (client Client) GetOwnerGraph(namespace string) (*OwnerGraph, error) {
+	objectCache := newObjectCache(nil)
+	for _, kind := range client.registry().Kinds() {
+		apiVersion, kindName := kind.GroupVersionKind.ToAPIVersionAndKind()
+		err := client.cacheAllObjectsOfKind(apiVersion, kindName, namespace, objectCache, false, metav1.ListOptions{})
+		if err != nil {
+			log.GetLogger().V(3).Info("Unable to list objects of kind " + kindName)
+		}
+	}
+	pods, err := client.getAllPods(namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		_, err := client.getTopController(pod, objectCache, &ownerWalk{})
+		if ctxErr := client.context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			log.GetLogger().V(1).Info("Unable to find the top level controller for this pod", pod.GetName(), pod.GetNamespace())
+		}
+	}
+
+	graph := &OwnerGraph{}
+	nodes := map[string]OwnerGraphNode{}
+	// names maps the IDs of the objects without their UID to their node IDs, for owner references without a UID.
+	names := map[WorkloadID]string{}
+	objects := append(objectCache.list(), pods...)
+	for _, object := range objects {
+		workloadID := NewWorkloadID(object)
+		id := workloadID.String()
+		names[workloadID.withoutUID()] = id
+		nodeType := OwnerGraphTopController
+		if object.GetKind() == "Pod" {
+			nodeType = OwnerGraphPod
+		} else if len(object.GetOwnerReferences()) > 0 {
+			nodeType = OwnerGraphIntermediate
+		}
+		nodes[id] = OwnerGraphNode{
+			ID:         id,
+			Type:       nodeType,
+			APIVersion: object.GetAPIVersion(),
+			Kind:       object.GetKind(),
+			Namespace:  object.GetNamespace(),
+			Name:       object.GetName(),
+			UID:        object.GetUID(),
+		}
+	}
+	for _, object := range objects {
+		for _, owner := range object.GetOwnerReferences() {
+			namespace := object.GetNamespace()
+			if owner.Kind == "Node" {
+				namespace = ""
+			}
+			ownerWorkload := ownerWorkloadID(namespace, owner)
+			ownerID := ownerWorkload.String()
+			if id, ok := names[ownerWorkload]; owner.UID == "" && ok {
+				ownerID = id
+			}
+			if _, ok := nodes[ownerID]; !ok {
+				nodeType := OwnerGraphUnresolved
+				if owner.Kind == "Node" {
+					nodeType = OwnerGraphExternal
+				}
+				nodes[ownerID] = OwnerGraphNode{
+					ID:         ownerID,
+					Type:       nodeType,
+					APIVersion: owner.APIVersion,
+					Kind:       owner.Kind,
+					Namespace:  namespace,
+					Name:       owner.Name,
+					UID:        owner.UID,
+				}
+			}
+			graph.Edges = append(graph.Edges, OwnerGraphEdge{
+				Owner:      ownerID,
+				Owned:      NewWorkloadID(object).String(),
+				Controller: owner.Controller != nil && *owner.Controller,
+			})
+		}
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Owner != graph.Edges[j].Owner {
+			return graph.Edges[i].Owner < graph.Edges[j].Owner
+		}
+		return graph.Edges[i].Owned < graph.Edges[j].Owned
+	})
+	return graph, nil
+}
+
+// JSON renders the graph as JSON.
+func (graph *OwnerGraph) JSON() ([]byte, error) {
+	return json.MarshalIndent(graph, "", "  ")
+}
+
+// DOT renders the graph in the Graphviz DOT language. Top level controllers are bold boxes, pods are
+// ellipses and unresolved owners are dashed red boxes. Owner references that aren't marked as the
+// controller are dashed edges.
+func (graph *OwnerGraph) DOT() string {
+	ids := graph.shortIDs()
+	var b strings.Builder
+	b.WriteString("digraph owners {\n")
+	b.WriteString("  rankdir=LR;\n")
+	for _, node := range graph.Nodes {
+		attributes := map[OwnerGraphNodeType]string{
+			OwnerGraphTopController: "shape=box, style=bold",
+			OwnerGraphIntermediate:  "shape=box",
+			OwnerGraphPod:           "shape=ellipse",
+			OwnerGraphUnresolved:    "shape=box, style=dashed, color=red",
+			OwnerGraphExternal:      "shape=box, style=dotted",
+		}[node.Type]
+		fmt.Fprintf(&b, "  %s [label=\"%s\", %s];\n", ids[node.ID], dotEscaper.Replace(node.label("\n")), attributes)
+	}
+	for _, edge := range graph.Edges {
+		style := ""
+		if !edge.Controller {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(&b, "  %s -> %s%s;\n", ids[edge.Owner], ids[edge.Owned], style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotEscaper escapes a DOT label. Unlike %q it leaves other characters, such as non-ASCII names, as they are.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Mermaid renders the graph as a Mermaid flowchart, with a class for each node type.
+// Owner references that aren't marked as the controller are dotted links.
+func (graph *OwnerGraph) Mermaid() string {
+	ids := graph.shortIDs()
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, node := range graph.Nodes {
+		label := strings.ReplaceAll(node.label("<br/>"), `"`, "#quot;")
+		fmt.Fprintf(&b, "  %s[\"%s\"]:::%s\n", ids[node.ID], label, strings.ToLower(string(node.Type)))
+	}
+	for _, edge := range graph.Edges {
+		arrow := "-->"
+		if !edge.Controller {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", ids[edge.Owner], arrow, ids[edge.Owned])
+	}
+	b.WriteString("  classDef topcontroller stroke-width:3px\n")
+	b.WriteString("  classDef intermediate stroke-width:1px\n")
+	b.WriteString("  classDef pod fill:#eef\n")
+	b.WriteString("  classDef unresolved stroke:#f00,stroke-dasharray:5 5\n")
+	b.WriteString("  classDef external stroke-dasharray:2 2\n")
+	return b.String()
+}
+
+// shortIDs maps node IDs to identifiers that are safe to use in DOT and Mermaid.
+func (graph *OwnerGraph) shortIDs() map[string]string {
+	ids := make(map[string]string, len(graph.Nodes))
+	for idx, node := range graph.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", idx)
+	}
+	return ids
+}
+
+func (node OwnerGraphNode) label(separator string) string {
+	if node.Namespace == "" {
+		return node.Kind + separator + node.Name
+	}
+	return node.Kind + separator + node.Namespace + "/" + node.Name
+}
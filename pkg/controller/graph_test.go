@@ -0,0 +1,74 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOwnerGraph(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	graph, err := client.GetOwnerGraph("")
+	assert.NoError(t, err)
+
+	types := map[string]OwnerGraphNodeType{}
+	for _, node := range graph.Nodes {
+		types[node.ID] = node.Type
+	}
+	assert.Equal(t, map[string]OwnerGraphNodeType{
+		"Deployment.apps/test/dep":         OwnerGraphTopController,
+		"Deployment.apps/test/dep-no-pods": OwnerGraphTopController,
+		"ReplicaSet.apps/test/rs":          OwnerGraphIntermediate,
+		"Pod.apps/test/poddy":              OwnerGraphPod,
+		"Pod.core/test2/poddy-bad":         OwnerGraphPod,
+		"ReplicaNotASet.core/test2/rs":     OwnerGraphUnresolved,
+	}, types)
+	assert.Equal(t, []OwnerGraphEdge{
+		{Owner: "Deployment.apps/test/dep", Owned: "ReplicaSet.apps/test/rs"},
+		{Owner: "ReplicaNotASet.core/test2/rs", Owned: "Pod.core/test2/poddy-bad"},
+		{Owner: "ReplicaSet.apps/test/rs", Owned: "Pod.apps/test/poddy"},
+	}, graph.Edges)
+
+	b, err := graph.JSON()
+	assert.NoError(t, err)
+	var decoded OwnerGraph
+	assert.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, *graph, decoded)
+
+	dot := graph.DOT()
+	assert.Contains(t, dot, "digraph owners {")
+	assert.Contains(t, dot, `n0 [label="Deployment\ntest/dep", shape=box, style=bold];`)
+	assert.Contains(t, dot, `label="ReplicaNotASet\ntest2/rs", shape=box, style=dashed, color=red`)
+	assert.Contains(t, dot, "n0 -> n5 [style=dashed];")
+
+	mermaid := graph.Mermaid()
+	assert.Contains(t, mermaid, "flowchart LR")
+	assert.Contains(t, mermaid, `n0["Deployment<br/>test/dep"]:::topcontroller`)
+	assert.Contains(t, mermaid, "n0 -.-> n5")
+	assert.Contains(t, mermaid, "classDef unresolved")
+}
+
+func TestOwnerGraphDOTLabels(t *testing.T) {
+	graph := &OwnerGraph{Nodes: []OwnerGraphNode{
+		{ID: "Deployment.apps/test/café", Kind: "Deployment", Namespace: "test", Name: "café", Type: OwnerGraphTopController},
+		{ID: `Thing.example.com/test/a"b\c`, Kind: "Thing", Namespace: "test", Name: `a"b\c`, Type: OwnerGraphExternal},
+	}}
+	dot := graph.DOT()
+	assert.Contains(t, dot, `[label="Deployment\ntest/café", shape=box, style=bold];`)
+	assert.Contains(t, dot, `[label="Thing\ntest/a\"b\\c", shape=box, style=dotted];`)
+}
@@ -0,0 +1,309 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// WorkloadHealth is the rollout status of a top controller, normalized across kinds.
+type WorkloadHealth struct {
+	// Desired is the number of replicas the controller wants. For a DaemonSet this is the number
+	// of nodes that should run the pod, and for a Job the number of completions.
+	Desired int
+	// Current is the number of pods the controller has created. For Jobs and CronJobs this is the number of active pods or jobs.
+	Current int
+	// Updated is the number of pods running the latest template.
+	Updated int
+	// Ready is the number of pods that are ready.
+	Ready int
+	// Available is the number of pods that have been ready for minReadySeconds. For a Job this is the number of succeeded pods.
+	Available int
+	// RolloutInProgress is set while the controller is converging on its latest spec.
+	RolloutInProgress bool
+	// Degraded is set when the controller reports a failure, or is short of replicas without a rollout in progress.
+	Degraded bool
+	// Reason explains RolloutInProgress or Degraded. It is empty for a healthy workload.
+	Reason string
+}
+
+// HealthFunc computes the health of a top controller from the object, usually from its status.
+type HealthFunc func(controller unstructured.Unstructured) WorkloadHealth
+
+// GetWorkloadHealth computes the health of a top controller using the DefaultRegistry.
+func GetWorkloadHealth(controller unstructured.Unstructured) (WorkloadHealth, bool) {
+	return DefaultRegistry.GetWorkloadHealth(controller)
+}
+
+// GetWorkloadHealth computes the health of a top controller with the Health function of its kind.
+// It returns false if the kind isn't registered or has no Health function.
+func (registry *KindRegistry) GetWorkloadHealth(controller unstructured.Unstructured) (WorkloadHealth, bool) {
+	info, ok := registry.lookupObject(controller.Object)
+	if !ok || info.Health == nil {
+		return WorkloadHealth{}, false
+	}
+	return info.Health(controller), true
+}
+
+func (registry *KindRegistry) workloadHealth(controller unstructured.Unstructured) *WorkloadHealth {
+	health, ok := registry.GetWorkloadHealth(controller)
+	if !ok {
+		return nil
+	}
+	return &health
+}
+
+// nestedNumber reads a number that may have been decoded as an int64 or, e.g. from YAML or by
+// encoding/json, as a float64. It returns false if the field is missing or isn't a number.
+func nestedNumber(obj map[string]interface{}, fields ...string) (int, bool) {
+	value, found, err := unstructured.NestedFieldNoCopy(obj, fields...)
+	if err != nil || !found {
+		return 0, false
+	}
+	switch number := value.(type) {
+	case int64:
+		return int(number), true
+	case int:
+		return number, true
+	case float64:
+		return int(number), true
+	}
+	return 0, false
+}
+
+func nestedInt(obj map[string]interface{}, fields ...string) int {
+	value, _ := nestedNumber(obj, fields...)
+	return value
+}
+
+// desiredReplicas reads spec.replicas, which defaults to one.
+func desiredReplicas(obj map[string]interface{}) int {
+	replicas, found := nestedNumber(obj, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+// generationObserved returns false while the controller hasn't seen the latest spec.
+func generationObserved(controller unstructured.Unstructured) bool {
+	return int64(nestedInt(controller.Object, "status", "observedGeneration")) >= controller.GetGeneration()
+}
+
+// findCondition returns the status condition of the given type, or nil.
+func findCondition(obj map[string]interface{}, conditionType string) map[string]interface{} {
+	conditions, found, err := unstructured.NestedFieldNoCopy(obj, "status", "conditions")
+	if err != nil || !found {
+		return nil
+	}
+	conditionList, ok := conditions.([]interface{})
+	if !ok {
+		return nil
+	}
+	for _, conditionI := range conditionList {
+		condition, ok := conditionI.(map[string]interface{})
+		if ok && condition["type"] == conditionType {
+			return condition
+		}
+	}
+	return nil
+}
+
+// conditionReason returns the reason of a condition, or its type if it has none.
+func conditionReason(condition map[string]interface{}) string {
+	if reason, ok := condition["reason"].(string); ok && reason != "" {
+		return reason
+	}
+	return fmt.Sprint(condition["type"])
+}
+
+func deploymentHealth(controller unstructured.Unstructured) WorkloadHealth {
+	obj := controller.Object
+	health := WorkloadHealth{
+		Desired:   desiredReplicas(obj),
+		Current:   nestedInt(obj, "status", "replicas"),
+		Updated:   nestedInt(obj, "status", "updatedReplicas"),
+		Ready:     nestedInt(obj, "status", "readyReplicas"),
+		Available: nestedInt(obj, "status", "availableReplicas"),
+	}
+	if condition := findCondition(obj, "Progressing"); condition != nil && condition["status"] == "False" {
+		health.Degraded = true
+		health.Reason = conditionReason(condition)
+		return health
+	}
+	if condition := findCondition(obj, "ReplicaFailure"); condition != nil && condition["status"] == "True" {
+		health.Degraded = true
+		health.Reason = conditionReason(condition)
+		return health
+	}
+	switch {
+	case !generationObserved(controller):
+		health.RolloutInProgress = true
+		health.Reason = "waiting for the deployment spec update to be observed"
+	case health.Updated < health.Desired:
+		health.RolloutInProgress = true
+		health.Reason = fmt.Sprintf("%d of %d replicas have been updated", health.Updated, health.Desired)
+	case health.Current > health.Updated:
+		health.RolloutInProgress = true
+		health.Reason = fmt.Sprintf("%d old replicas are pending termination", health.Current-health.Updated)
+	case health.Available < health.Updated:
+		health.RolloutInProgress = true
+		health.Reason = fmt.Sprintf("%d of %d updated replicas are available", health.Available, health.Updated)
+	}
+	if !health.RolloutInProgress && health.Available < health.Desired {
+		health.Degraded = true
+		health.Reason = fmt.Sprintf("%d of %d replicas are available", health.Available, health.Desired)
+	}
+	return health
+}
+
+func replicaSetHealth(controller unstructured.Unstructured) WorkloadHealth {
+	obj := controller.Object
+	health := WorkloadHealth{
+		Desired:   desiredReplicas(obj),
+		Current:   nestedInt(obj, "status", "replicas"),
+		Ready:     nestedInt(obj, "status", "readyReplicas"),
+		Available: nestedInt(obj, "status", "availableReplicas"),
+	}
+	// A ReplicaSet has a single template, so every replica it owns is up to date.
+	health.Updated = health.Current
+	if condition := findCondition(obj, "ReplicaFailure"); condition != nil && condition["status"] == "True" {
+		health.Degraded = true
+		health.Reason = conditionReason(condition)
+		return health
+	}
+	switch {
+	case !generationObserved(controller):
+		health.RolloutInProgress = true
+		health.Reason = "waiting for the replica set spec update to be observed"
+	case health.Current != health.Desired:
+		health.RolloutInProgress = true
+		health.Reason = fmt.Sprintf("scaling from %d to %d replicas", health.Current, health.Desired)
+	case health.Available < health.Desired:
+		health.Degraded = true
+		health.Reason = fmt.Sprintf("%d of %d replicas are available", health.Available, health.Desired)
+	}
+	return health
+}
+
+func statefulSetHealth(controller unstructured.Unstructured) WorkloadHealth {
+	obj := controller.Object
+	health := WorkloadHealth{
+		Desired:   desiredReplicas(obj),
+		Current:   nestedInt(obj, "status", "replicas"),
+		Updated:   nestedInt(obj, "status", "updatedReplicas"),
+		Ready:     nestedInt(obj, "status", "readyReplicas"),
+		Available: nestedInt(obj, "status", "availableReplicas"),
+	}
+	currentRevision, _, _ := unstructured.NestedString(obj, "status", "currentRevision")
+	updateRevision, _, _ := unstructured.NestedString(obj, "status", "updateRevision")
+	strategy, _, _ := unstructured.NestedString(obj, "spec", "updateStrategy", "type")
+	switch {
+	case !generationObserved(controller):
+		health.RolloutInProgress = true
+		health.Reason = "waiting for the stateful set spec update to be observed"
+	case strategy != "OnDelete" && health.Updated < health.Desired:
+		health.RolloutInProgress = true
+		health.Reason = fmt.Sprintf("%d of %d replicas have been updated", health.Updated, health.Desired)
+	case strategy != "OnDelete" && currentRevision != updateRevision:
+		health.RolloutInProgress = true
+		health.Reason = fmt.Sprintf("waiting for revision %s to replace %s", updateRevision, currentRevision)
+	case health.Ready < health.Desired:
+		health.Degraded = true
+		health.Reason = fmt.Sprintf("%d of %d replicas are ready", health.Ready, health.Desired)
+	}
+	return health
+}
+
+func daemonSetHealth(controller unstructured.Unstructured) WorkloadHealth {
+	obj := controller.Object
+	health := WorkloadHealth{
+		Desired:   nestedInt(obj, "status", "desiredNumberScheduled"),
+		Current:   nestedInt(obj, "status", "currentNumberScheduled"),
+		Updated:   nestedInt(obj, "status", "updatedNumberScheduled"),
+		Ready:     nestedInt(obj, "status", "numberReady"),
+		Available: nestedInt(obj, "status", "numberAvailable"),
+	}
+	unavailable := nestedInt(obj, "status", "numberUnavailable")
+	strategy, _, _ := unstructured.NestedString(obj, "spec", "updateStrategy", "type")
+	switch {
+	case !generationObserved(controller):
+		health.RolloutInProgress = true
+		health.Reason = "waiting for the daemon set spec update to be observed"
+	case strategy != "OnDelete" && health.Updated < health.Desired:
+		health.RolloutInProgress = true
+		health.Reason = fmt.Sprintf("%d of %d pods have been updated", health.Updated, health.Desired)
+	case unavailable > 0:
+		health.Degraded = true
+		health.Reason = fmt.Sprintf("%d of %d pods are unavailable", unavailable, health.Desired)
+	case nestedInt(obj, "status", "numberMisscheduled") > 0:
+		health.Degraded = true
+		health.Reason = fmt.Sprintf("%d pods are running on nodes they shouldn't", nestedInt(obj, "status", "numberMisscheduled"))
+	}
+	return health
+}
+
+func jobHealth(controller unstructured.Unstructured) WorkloadHealth {
+	obj := controller.Object
+	completions, found := nestedNumber(obj, "spec", "completions")
+	if !found {
+		completions = 1
+	}
+	health := WorkloadHealth{
+		Desired:   completions,
+		Current:   nestedInt(obj, "status", "active"),
+		Ready:     nestedInt(obj, "status", "ready"),
+		Available: nestedInt(obj, "status", "succeeded"),
+	}
+	health.Updated = health.Current
+	failed := nestedInt(obj, "status", "failed")
+	if condition := findCondition(obj, "Failed"); condition != nil && condition["status"] == "True" {
+		health.Degraded = true
+		health.Reason = conditionReason(condition)
+		return health
+	}
+	if condition := findCondition(obj, "Complete"); condition != nil && condition["status"] == "True" {
+		return health
+	}
+	if suspended, _, _ := unstructured.NestedBool(obj, "spec", "suspend"); suspended {
+		// A suspended Job isn't making progress, but it isn't failing either.
+		health.Reason = "suspended"
+		return health
+	}
+	health.RolloutInProgress = true
+	health.Reason = fmt.Sprintf("%d of %d completions have succeeded", health.Available, health.Desired)
+	if failed > 0 {
+		health.Degraded = true
+		health.Reason = fmt.Sprintf("%d pods have failed", failed)
+	}
+	return health
+}
+
+// cronJobHealth only reports the running jobs, since a CronJob has no replicas of its own.
+func cronJobHealth(controller unstructured.Unstructured) WorkloadHealth {
+	obj := controller.Object
+	health := WorkloadHealth{}
+	if active, found, err := unstructured.NestedFieldNoCopy(obj, "status", "active"); err == nil && found {
+		if activeList, ok := active.([]interface{}); ok {
+			health.Current = len(activeList)
+		}
+	}
+	if suspended, _, _ := unstructured.NestedBool(obj, "spec", "suspend"); suspended {
+		health.Reason = "suspended"
+	}
+	return health
+}
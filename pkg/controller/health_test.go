@@ -0,0 +1,135 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newController(apiVersion, kind string, spec, status map[string]interface{}) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": "controller", "namespace": "test", "generation": int64(2)},
+		"spec":       spec,
+		"status":     status,
+	}}
+}
+
+func TestGetWorkloadHealth(t *testing.T) {
+	testCases := []struct {
+		name       string
+		controller unstructured.Unstructured
+		expected   WorkloadHealth
+	}{{
+		name: "healthy deployment",
+		controller: newController("apps/v1", "Deployment", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+			"observedGeneration": int64(2), "replicas": int64(3), "updatedReplicas": int64(3), "readyReplicas": int64(3), "availableReplicas": int64(3),
+		}),
+		expected: WorkloadHealth{Desired: 3, Current: 3, Updated: 3, Ready: 3, Available: 3},
+	}, {
+		name: "deployment rolling out",
+		controller: newController("apps/v1", "Deployment", map[string]interface{}{"replicas": int64(3)}, map[string]interface{}{
+			"observedGeneration": int64(2), "replicas": int64(4), "updatedReplicas": int64(3), "readyReplicas": int64(4), "availableReplicas": int64(4),
+		}),
+		expected: WorkloadHealth{Desired: 3, Current: 4, Updated: 3, Ready: 4, Available: 4, RolloutInProgress: true, Reason: "1 old replicas are pending termination"},
+	}, {
+		name: "deployment past its progress deadline",
+		controller: newController("apps/v1", "Deployment", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+			"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(1),
+			"conditions": []interface{}{map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"}},
+		}),
+		expected: WorkloadHealth{Desired: 2, Current: 2, Updated: 1, Degraded: true, Reason: "ProgressDeadlineExceeded"},
+	}, {
+		name: "stateful set between revisions",
+		controller: newController("apps/v1", "StatefulSet", map[string]interface{}{"replicas": int64(2)}, map[string]interface{}{
+			"observedGeneration": int64(2), "replicas": int64(2), "updatedReplicas": int64(2), "readyReplicas": int64(2),
+			"currentRevision": "web-1", "updateRevision": "web-2",
+		}),
+		expected: WorkloadHealth{Desired: 2, Current: 2, Updated: 2, Ready: 2, RolloutInProgress: true, Reason: "waiting for revision web-2 to replace web-1"},
+	}, {
+		name: "daemon set with unavailable pods",
+		controller: newController("apps/v1", "DaemonSet", map[string]interface{}{}, map[string]interface{}{
+			"observedGeneration": int64(2), "desiredNumberScheduled": int64(3), "currentNumberScheduled": int64(3), "updatedNumberScheduled": int64(3),
+			"numberReady": int64(2), "numberAvailable": int64(2), "numberUnavailable": int64(1),
+		}),
+		expected: WorkloadHealth{Desired: 3, Current: 3, Updated: 3, Ready: 2, Available: 2, Degraded: true, Reason: "1 of 3 pods are unavailable"},
+	}, {
+		name: "failed job",
+		controller: newController("batch/v1", "Job", map[string]interface{}{"completions": int64(2)}, map[string]interface{}{
+			"succeeded": int64(1), "failed": int64(6),
+			"conditions": []interface{}{map[string]interface{}{"type": "Failed", "status": "True", "reason": "BackoffLimitExceeded"}},
+		}),
+		expected: WorkloadHealth{Desired: 2, Available: 1, Degraded: true, Reason: "BackoffLimitExceeded"},
+	}, {
+		name: "running job",
+		controller: newController("batch/v1", "Job", map[string]interface{}{}, map[string]interface{}{
+			"active": int64(1), "ready": int64(1),
+		}),
+		expected: WorkloadHealth{Desired: 1, Current: 1, Updated: 1, Ready: 1, RolloutInProgress: true, Reason: "0 of 1 completions have succeeded"},
+	}, {
+		name: "suspended job",
+		controller: newController("batch/v1", "Job", map[string]interface{}{"completions": int64(3), "suspend": true}, map[string]interface{}{
+			"succeeded": int64(1), "failed": int64(1),
+			"conditions": []interface{}{map[string]interface{}{"type": "Suspended", "status": "True", "reason": "JobSuspended"}},
+		}),
+		expected: WorkloadHealth{Desired: 3, Available: 1, Reason: "suspended"},
+	}, {
+		name: "deployment decoded with float64 numbers",
+		controller: newController("apps/v1", "Deployment", map[string]interface{}{"replicas": float64(3)}, map[string]interface{}{
+			"observedGeneration": float64(2), "replicas": float64(3), "updatedReplicas": float64(3), "readyReplicas": float64(2), "availableReplicas": float64(2),
+		}),
+		expected: WorkloadHealth{Desired: 3, Current: 3, Updated: 3, Ready: 2, Available: 2, RolloutInProgress: true, Reason: "2 of 3 updated replicas are available"},
+	}, {
+		name: "suspended cron job",
+		controller: newController("batch/v1", "CronJob", map[string]interface{}{"suspend": true}, map[string]interface{}{
+			"active": []interface{}{map[string]interface{}{"name": "job"}},
+		}),
+		expected: WorkloadHealth{Current: 1, Reason: "suspended"},
+	}}
+	for _, tc := range testCases {
+		health, ok := GetWorkloadHealth(tc.controller)
+		assert.True(t, ok, tc.name)
+		assert.Equal(t, tc.expected, health, tc.name)
+	}
+
+	registry := NewKindRegistry()
+	scaledJob := newScaledJob("scaled")
+	_, ok := registry.GetWorkloadHealth(scaledJob)
+	assert.False(t, ok)
+	registry.Register(KindInfo{
+		GroupVersionKind: scaledJobKind,
+		Health: func(controller unstructured.Unstructured) WorkloadHealth {
+			return WorkloadHealth{Degraded: true, Reason: "custom " + controller.GetName()}
+		},
+	})
+	health, ok := registry.GetWorkloadHealth(scaledJob)
+	assert.True(t, ok)
+	assert.Equal(t, WorkloadHealth{Degraded: true, Reason: "custom scaled"}, health)
+}
+
+func TestWorkloadHealth(t *testing.T) {
+	client, _, _, _, pod2 := setupFakeData(t)
+	workloads, err := client.GetAllTopControllersSummary("")
+	assert.NoError(t, err)
+	dep := findWorkload(workloads, "dep")
+	assert.NotNil(t, dep.Health)
+	assert.Equal(t, 1, dep.Health.Desired)
+	assert.True(t, dep.Health.RolloutInProgress)
+	assert.Nil(t, findWorkload(workloads, pod2.GetName()).Health)
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fairwindsops/controller-utils/pkg/log"
+)
+
+// DefaultPageSize is the number of items requested per page when Client.PageSize is not set.
+const DefaultPageSize int64 = 500
+
+// maxListRestarts is how many times a paginated list is restarted after its continue token
+// expires before falling back to a single unpaginated list.
+const maxListRestarts = 2
+
+// ListStats describes a single paginated list call.
+type ListStats struct {
+	Resource  schema.GroupVersionResource
+	Namespace string
+	// Pages is the number of list requests that returned successfully.
+	Pages int
+	// Items is the number of items returned in the end.
+	Items int
+	// Restarts is the number of times paging restarted because the continue token had expired.
+	Restarts int
+	// FullRelist is set when paging was abandoned for a single unpaginated list.
+	FullRelist bool
+}
+
+func (client Client) pageSize() int64 {
+	if client.PageSize > 0 {
+		return client.PageSize
+	}
+	return DefaultPageSize
+}
+
+// listPageFunc fetches one page of a resource, and returns its items and the continue token.
+type listPageFunc func(resource schema.GroupVersionResource, namespace string, listOptions metav1.ListOptions) ([]unstructured.Unstructured, string, error)
+
+// listAll pages through every object of a resource. If a continue token expires part way through,
+// the list is restarted from the beginning so that the result stays consistent, and after too many
+// restarts it falls back to one unpaginated list.
+func (client Client) listAll(resource schema.GroupVersionResource, namespace string, listOptions metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	return client.listAllPages(resource, namespace, listOptions, client.listPage)
+}
+
+func (client Client) listPage(resource schema.GroupVersionResource, namespace string, listOptions metav1.ListOptions) ([]unstructured.Unstructured, string, error) {
+	list, err := client.Dynamic.Resource(resource).Namespace(namespace).List(client.context(), listOptions)
+	if err != nil {
+		return nil, "", err
+	}
+	return list.Items, list.GetContinue(), nil
+}
+
+// listAllPages is listAll with the given way of fetching each page.
+func (client Client) listAllPages(resource schema.GroupVersionResource, namespace string, listOptions metav1.ListOptions, listPage listPageFunc) ([]unstructured.Unstructured, error) {
+	stats := ListStats{Resource: resource, Namespace: namespace}
+	listOptions.Limit = client.pageSize()
+	listOptions.Continue = ""
+	var items []unstructured.Unstructured
+	for {
+		if err := client.context().Err(); err != nil {
+			return nil, err
+		}
+		page, continueToken, err := listPage(resource, namespace, listOptions)
+		if err != nil {
+			if listOptions.Continue == "" || !(apierrors.IsResourceExpired(err) || apierrors.IsGone(err)) {
+				return nil, err
+			}
+			stats.Restarts++
+			log.GetLogger().V(3).Info("Continue token expired, restarting list", "resource", resource.String(), "namespace", namespace, "restarts", stats.Restarts)
+			items = nil
+			listOptions.Continue = ""
+			if stats.Restarts > maxListRestarts {
+				stats.FullRelist = true
+				listOptions.Limit = 0
+			}
+			continue
+		}
+		stats.Pages++
+		items = append(items, page...)
+		listOptions.Continue = continueToken
+		if listOptions.Continue == "" {
+			break
+		}
+	}
+	stats.Items = len(items)
+	log.GetLogger().V(3).Info("Listed objects", "resource", resource.String(), "namespace", namespace, "pages", stats.Pages, "items", stats.Items)
+	if client.ListStatsHook != nil {
+		client.ListStatsHook(stats)
+	}
+	return items, nil
+}
+
+// restMapping maps a kind to its resource, returning a *MappingNotFoundError if it can't.
+func (client Client) restMapping(fqKind schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := client.RESTMapper.RESTMapping(fqKind.GroupKind(), fqKind.Version)
+	if err != nil {
+		log.GetLogger().Error(err, "Error retrieving mapping", fqKind.GroupVersion().String(), fqKind.Kind)
+		return nil, &MappingNotFoundError{GroupVersionKind: fqKind, Err: err}
+	}
+	return mapping, nil
+}
+
+// listKind lists every object of a kind, returning a *ForbiddenError or *ListFailedError if it can't.
+func (client Client) listKind(fqKind schema.GroupVersionKind, namespace string, listOptions metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	return client.listKindPages(fqKind, namespace, listOptions, client.listPage)
+}
+
+func (client Client) listKindPages(fqKind schema.GroupVersionKind, namespace string, listOptions metav1.ListOptions, listPage listPageFunc) ([]unstructured.Unstructured, error) {
+	if err := client.context().Err(); err != nil {
+		return nil, err
+	}
+	mapping, err := client.restMapping(fqKind)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := client.listAllPages(mapping.Resource, namespace, listOptions, listPage)
+	if ctxErr := client.context().Err(); err != nil && ctxErr != nil {
+		return nil, ctxErr
+	}
+	if apierrors.IsForbidden(err) {
+		return nil, &ForbiddenError{GroupVersionKind: fqKind, Namespace: namespace, Err: err}
+	}
+	if err != nil {
+		return nil, &ListFailedError{GroupVersionKind: fqKind, Namespace: namespace, Err: err}
+	}
+	return objects, nil
+}
+
+// runTasks runs the tasks with at most Client.Concurrency of them at a time, and returns once they are all done.
+// Tasks that haven't started when Client.Context is done are skipped.
+func (client Client) runTasks(tasks []func()) {
+	if client.Concurrency <= 1 {
+		for _, task := range tasks {
+			if client.context().Err() != nil {
+				return
+			}
+			task()
+		}
+		return
+	}
+	queue := make(chan func())
+	var wg sync.WaitGroup
+	for i := 0; i < client.Concurrency && i < len(tasks); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				if client.context().Err() == nil {
+					task()
+				}
+			}
+		}()
+	}
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+}
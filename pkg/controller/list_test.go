@@ -0,0 +1,132 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// pagedDynamic serves pod lists in pages of the requested size, since the fake dynamic
+// client ignores Limit and Continue. The first expiredContinues requests that carry a
+// continue token fail with 410 Gone.
+type pagedDynamic struct {
+	dynamic.Interface
+	podCount         int
+	expiredContinues int
+	err              error
+}
+
+type pagedResource struct {
+	dynamic.NamespaceableResourceInterface
+	paged *pagedDynamic
+}
+
+func (paged *pagedDynamic) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return pagedResource{paged.Interface.Resource(resource), paged}
+}
+
+func (resource pagedResource) Namespace(string) dynamic.ResourceInterface {
+	return resource
+}
+
+func (resource pagedResource) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	paged := resource.paged
+	if paged.err != nil {
+		return nil, paged.err
+	}
+	start := 0
+	if opts.Continue != "" {
+		if paged.expiredContinues > 0 {
+			paged.expiredContinues--
+			return nil, apierrors.NewResourceExpired("continue token expired")
+		}
+		start, _ = strconv.Atoi(opts.Continue)
+	}
+	end := paged.podCount
+	if opts.Limit > 0 && start+int(opts.Limit) < paged.podCount {
+		end = start + int(opts.Limit)
+	}
+	list := &unstructured.UnstructuredList{}
+	for i := start; i < end; i++ {
+		pod := unstructured.Unstructured{}
+		pod.SetAPIVersion("v1")
+		pod.SetKind("Pod")
+		pod.SetName(fmt.Sprintf("pod-%d", i))
+		pod.SetNamespace("paged")
+		list.Items = append(list.Items, pod)
+	}
+	if end < paged.podCount {
+		list.SetContinue(strconv.Itoa(end))
+	}
+	return list, nil
+}
+
+func setupPagedClient(t *testing.T, podCount int, expiredContinues int) (Client, *pagedDynamic, *[]ListStats) {
+	client, _, _, _, _ := setupFakeData(t)
+	paged := &pagedDynamic{Interface: client.Dynamic, podCount: podCount, expiredContinues: expiredContinues}
+	stats := []ListStats{}
+	client.Dynamic = paged
+	client.ListStatsHook = func(s ListStats) {
+		stats = append(stats, s)
+	}
+	return client, paged, &stats
+}
+
+func TestListAllPaginates(t *testing.T) {
+	client, _, stats := setupPagedClient(t, 25, 0)
+	client.PageSize = 10
+	pods, err := client.getAllPods("paged", metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods, 25)
+	assert.Equal(t, []ListStats{{Resource: podsResource, Namespace: "paged", Pages: 3, Items: 25}}, *stats)
+}
+
+func TestListAllRestartsOnExpiredContinue(t *testing.T) {
+	client, _, stats := setupPagedClient(t, 25, 1)
+	client.PageSize = 10
+	pods, err := client.getAllPods("paged", metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods, 25)
+	assert.Equal(t, 1, (*stats)[0].Restarts)
+	assert.Equal(t, 4, (*stats)[0].Pages)
+	assert.False(t, (*stats)[0].FullRelist)
+
+	client, _, stats = setupPagedClient(t, 25, 10)
+	client.PageSize = 10
+	pods, err = client.getAllPods("paged", metav1.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, pods, 25)
+	assert.True(t, (*stats)[0].FullRelist)
+	assert.Equal(t, 25, (*stats)[0].Items)
+}
+
+func TestListAllReturnsOtherErrors(t *testing.T) {
+	client, paged, _ := setupPagedClient(t, 25, 0)
+	paged.err = apierrors.NewForbidden(podsResource.GroupResource(), "", fmt.Errorf("nope"))
+	_, err := client.getAllPods("paged", metav1.ListOptions{})
+	assert.True(t, apierrors.IsForbidden(err))
+}
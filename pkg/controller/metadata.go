@@ -0,0 +1,115 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// listKindMetadata is listKind through Client.Metadata. The objects only have their apiVersion, kind and
+// metadata, without managedFields.
+func (client Client) listKindMetadata(fqKind schema.GroupVersionKind, namespace string, listOptions metav1.ListOptions) ([]unstructured.Unstructured, error) {
+	return client.listKindPages(fqKind, namespace, listOptions, func(resource schema.GroupVersionResource, namespace string, listOptions metav1.ListOptions) ([]unstructured.Unstructured, string, error) {
+		list, err := client.Metadata.Resource(resource).Namespace(namespace).List(client.context(), listOptions)
+		if err != nil {
+			return nil, "", err
+		}
+		items := make([]unstructured.Unstructured, 0, len(list.Items))
+		for idx := range list.Items {
+			item := &list.Items[idx]
+			item.ManagedFields = nil
+			metadata, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&item.ObjectMeta)
+			if err != nil {
+				return nil, "", err
+			}
+			object := unstructured.Unstructured{Object: map[string]interface{}{"metadata": metadata}}
+			object.SetGroupVersionKind(fqKind)
+			items = append(items, object)
+		}
+		return items, list.GetContinue(), nil
+	})
+}
+
+// isPartial returns true if the top controller of an object was listed through Client.Metadata,
+// and so only has its metadata.
+func (client Client) isPartial(object, controller unstructured.Unstructured) bool {
+	if client.Metadata == nil || NewWorkloadID(object) == NewWorkloadID(controller) {
+		return false
+	}
+	return isMetadataOnly(controller)
+}
+
+func isMetadataOnly(object unstructured.Unstructured) bool {
+	for field := range object.Object {
+		if field != "apiVersion" && field != "kind" && field != "metadata" {
+			return false
+		}
+	}
+	return true
+}
+
+// getFullObject fetches the whole of an object that was listed through Client.Metadata.
+func (client Client) getFullObject(object unstructured.Unstructured) (unstructured.Unstructured, error) {
+	if err := client.context().Err(); err != nil {
+		return object, err
+	}
+	fqKind := object.GroupVersionKind()
+	mapping, err := client.restMapping(fqKind)
+	if err != nil {
+		return object, err
+	}
+	full, err := client.Dynamic.Resource(mapping.Resource).Namespace(object.GetNamespace()).Get(client.context(), object.GetName(), metav1.GetOptions{})
+	if apierrors.IsForbidden(err) {
+		return object, &ForbiddenError{GroupVersionKind: fqKind, Namespace: object.GetNamespace(), Err: err}
+	}
+	if err != nil {
+		return object, fmt.Errorf("fetching %s %s: %w", fqKind.Kind, objectName(object.GetNamespace(), object.GetName()), err)
+	}
+	return *full, nil
+}
+
+// getFullObjects fetches the whole of each object that only has its metadata, with at most Client.Concurrency
+// requests at a time. Objects that can't be fetched are recorded as failures and returned as they were.
+func (client Client) getFullObjects(objects []unstructured.Unstructured, failures *discoveryFailures) []unstructured.Unstructured {
+	full := make([]unstructured.Unstructured, len(objects))
+	tasks := make([]func(), 0, len(objects))
+	for idx := range objects {
+		idx := idx
+		full[idx] = objects[idx]
+		if !isMetadataOnly(objects[idx]) {
+			continue
+		}
+		tasks = append(tasks, func() {
+			var err error
+			full[idx], err = client.getFullObject(objects[idx])
+			if err != nil {
+				failures.add(DiscoveryFailure{
+					GroupVersionKind: objects[idx].GroupVersionKind(),
+					Namespace:        objects[idx].GetNamespace(),
+					Name:             objects[idx].GetName(),
+					Err:              err,
+				}, false)
+			}
+		})
+	}
+	client.runTasks(tasks)
+	return full
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	deploymentsResource = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	replicaSetsResource = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}
+)
+
+// newMetadataClient serves the metadata of every object of the resources in the client's dynamic fake.
+func newMetadataClient(t testing.TB, client Client, resources ...schema.GroupVersionResource) *metadatafake.FakeMetadataClient {
+	objects := []runtime.Object{}
+	for _, resource := range resources {
+		list, err := client.Dynamic.Resource(resource).List(context.TODO(), metav1.ListOptions{})
+		assert.NoError(t, err)
+		for _, item := range list.Items {
+			object := &metav1.PartialObjectMetadata{TypeMeta: metav1.TypeMeta{APIVersion: item.GetAPIVersion(), Kind: item.GetKind()}}
+			assert.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object["metadata"].(map[string]interface{}), &object.ObjectMeta))
+			objects = append(objects, object)
+		}
+	}
+	scheme := metadatafake.NewTestScheme()
+	assert.NoError(t, metav1.AddMetaToScheme(scheme))
+	return metadatafake.NewSimpleMetadataClient(scheme, objects...)
+}
+
+func createObject(t testing.TB, client Client, resource schema.GroupVersionResource, object unstructured.Unstructured) {
+	_, err := client.Dynamic.Resource(resource).Namespace(object.GetNamespace()).Create(context.TODO(), &object, metav1.CreateOptions{})
+	assert.NoError(t, err)
+}
+
+func TestMetadataDiscovery(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	deployment := unstructured.Unstructured{Object: readFile(t, "./testdata/deployment.json")}
+	deployment.SetName("full")
+	deployment.SetNamespace("test")
+	createObject(t, client, deploymentsResource, deployment)
+	replicaSet := unstructured.Unstructured{Object: readFile(t, "./testdata/replica-set.json")}
+	replicaSet.SetName("full-rs")
+	replicaSet.SetNamespace("test")
+	replicaSet.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "full"}})
+	createObject(t, client, replicaSetsResource, replicaSet)
+	pod := newPodWithOwners("full-pod", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "full-rs"})
+	createObject(t, client, podsResource, pod)
+
+	expected, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+
+	client.Metadata = newMetadataClient(t, client, deploymentsResource, replicaSetsResource)
+	dynamic := client.Dynamic.(*dynamicfake.FakeDynamicClient)
+	dynamic.ClearActions()
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.Len(t, workloads, len(expected))
+	for _, workload := range expected {
+		actual := findWorkload(workloads, workload.TopController.GetName())
+		assert.NotNil(t, actual)
+		assert.Equal(t, workload.TopController, actual.TopController)
+		assert.Equal(t, workload.PodSpec, actual.PodSpec)
+		assert.Equal(t, workload.PodCount, actual.PodCount)
+		assert.Equal(t, workload.Health, actual.Health)
+	}
+	for _, action := range dynamic.Actions() {
+		// Replica sets are only listed as metadata, and deployments with a spec aren't fetched again.
+		if action.GetVerb() == "list" {
+			assert.Contains(t, []string{"deployments", "pods"}, action.GetResource().Resource)
+		} else {
+			assert.Equal(t, "get", action.GetVerb())
+			assert.NotEqual(t, "full", action.(k8stesting.GetAction).GetName())
+		}
+	}
+
+	controller, err := client.GetTopController(pod, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, deployment.Object["spec"], controller.Object["spec"])
+}
+
+// addManagedFields gives an object the kind of managedFields and status a busy cluster has.
+func addManagedFields(object *unstructured.Unstructured) {
+	fields := []metav1.ManagedFieldsEntry{}
+	for i := 0; i < 10; i++ {
+		fields = append(fields, metav1.ManagedFieldsEntry{
+			Manager:    fmt.Sprintf("manager-%d", i),
+			Operation:  metav1.ManagedFieldsOperationApply,
+			APIVersion: "apps/v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:note":{".":{},"f:value":"` + strings.Repeat("x", 512) + `"}}}`)},
+		})
+	}
+	object.SetManagedFields(fields)
+	object.Object["status"] = map[string]interface{}{
+		"replicas":   int64(3),
+		"conditions": []interface{}{map[string]interface{}{"type": "Available", "status": "True", "message": strings.Repeat("y", 512)}},
+	}
+}
+
+// setupSyntheticCluster creates deployments with several old replica sets each, and pods for the newest one.
+func setupSyntheticCluster(b *testing.B, deployments, replicaSets, pods int) Client {
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+	client := Client{
+		Context: context.TODO(),
+		Dynamic: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			deploymentsResource: "DeploymentList",
+			replicaSetsResource: "ReplicaSetList",
+			podsResource:        "PodList",
+		}),
+		RESTMapper: restMapper,
+		Registry:   &KindRegistry{kinds: NewKindRegistry().kinds[:2]},
+	}
+	template := readFile(b, "./testdata/deployment.json")
+	for d := 0; d < deployments; d++ {
+		deployment := unstructured.Unstructured{Object: runtime.DeepCopyJSON(template)}
+		deployment.SetName(fmt.Sprintf("dep-%d", d))
+		deployment.SetNamespace("test")
+		addManagedFields(&deployment)
+		createObject(b, client, deploymentsResource, deployment)
+		for r := 0; r < replicaSets; r++ {
+			replicaSet := unstructured.Unstructured{Object: runtime.DeepCopyJSON(template)}
+			replicaSet.SetKind("ReplicaSet")
+			replicaSet.SetName(fmt.Sprintf("dep-%d-rs-%d", d, r))
+			replicaSet.SetNamespace("test")
+			replicaSet.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: deployment.GetName()}})
+			addManagedFields(&replicaSet)
+			createObject(b, client, replicaSetsResource, replicaSet)
+		}
+		for p := 0; p < pods; p++ {
+			pod := newPodWithOwners(fmt.Sprintf("dep-%d-pod-%d", d, p), metav1.OwnerReference{
+				APIVersion: "apps/v1", Kind: "ReplicaSet", Name: fmt.Sprintf("dep-%d-rs-%d", d, replicaSets-1),
+			})
+			createObject(b, client, podsResource, pod)
+		}
+	}
+	return client
+}
+
+// BenchmarkDiscovery compares the memory used by discovery with full objects and with metadata only.
+// Run with: go test -run=^$ -bench=Discovery -benchmem ./pkg/controller
+func BenchmarkDiscovery(b *testing.B) {
+	client := setupSyntheticCluster(b, 50, 10, 3)
+	metadataClient := client
+	metadataClient.Metadata = newMetadataClient(b, client, deploymentsResource, replicaSetsResource)
+	for name, client := range map[string]Client{"full": client, "metadata": metadataClient} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				workloads, err := client.GetAllTopControllersSummary("test")
+				if err != nil || len(workloads) != 50 {
+					b.Fatalf("expected 50 workloads, got %d: %v", len(workloads), err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// objectCache holds the objects that have been listed during discovery, keyed by WorkloadID.
+// It is safe for concurrent use.
+type objectCache struct {
+	mu      sync.RWMutex
+	objects map[WorkloadID]unstructured.Unstructured
+	// names maps IDs without their UID to the ID of the last object set with that name.
+	names map[WorkloadID]WorkloadID
+	// legacy is the map passed to GetTopController, which is kept filled in under getControllerKey.
+	legacy map[string]unstructured.Unstructured
+	// listed holds the listedKey of every kind and namespace whose objects were all listed.
+	listed map[string]bool
+}
+
+// newObjectCache wraps an existing map, so that callers passing their own cache to
+// GetTopController still see it filled in.
+func newObjectCache(legacy map[string]unstructured.Unstructured) *objectCache {
+	cache := &objectCache{
+		objects: map[WorkloadID]unstructured.Unstructured{},
+		names:   map[WorkloadID]WorkloadID{},
+		legacy:  legacy,
+		listed:  map[string]bool{},
+	}
+	for key, object := range legacy {
+		if object.GetName() == "" {
+			cache.listed[key] = true
+			continue
+		}
+		cache.add(object)
+	}
+	return cache
+}
+
+// listedKey is the key that records that every object of a kind was listed in a namespace, or in the whole
+// cluster if it is empty. Names are never empty, so it doesn't clash with the key of an object.
+func listedKey(groupKind schema.GroupKind, namespace string) string {
+	return groupKind.String() + "/" + namespace + "/"
+}
+
+// setListed records that every object of a kind was listed in a namespace. In the map passed by the
+// caller, this is an entry without a name.
+func (cache *objectCache) setListed(fqKind schema.GroupVersionKind, namespace string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	key := listedKey(fqKind.GroupKind(), namespace)
+	cache.listed[key] = true
+	if cache.legacy != nil {
+		marker := unstructured.Unstructured{Object: map[string]interface{}{}}
+		marker.SetGroupVersionKind(fqKind)
+		marker.SetNamespace(namespace)
+		cache.legacy[key] = marker
+	}
+}
+
+// wasListed returns true if every object of a kind was listed in the namespace, or in the whole cluster.
+func (cache *objectCache) wasListed(groupKind schema.GroupKind, namespace string) bool {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	return cache.listed[listedKey(groupKind, namespace)] || cache.listed[listedKey(groupKind, "")]
+}
+
+// get returns the object with the ID. If the ID has no UID, it returns the object with that name.
+func (cache *objectCache) get(id WorkloadID) (unstructured.Unstructured, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	if id.UID == "" {
+		var ok bool
+		if id, ok = cache.names[id]; !ok {
+			return unstructured.Unstructured{}, false
+		}
+	}
+	object, ok := cache.objects[id]
+	return object, ok
+}
+
+// getByName returns the object with the same name as the ID, whatever its UID.
+func (cache *objectCache) getByName(id WorkloadID) (unstructured.Unstructured, bool) {
+	return cache.get(id.withoutUID())
+}
+
+func (cache *objectCache) set(object unstructured.Unstructured) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.add(object)
+	if cache.legacy != nil {
+		cache.legacy[getControllerKey(object)] = object
+	}
+}
+
+// add stores an object. Must be called with mu held.
+func (cache *objectCache) add(object unstructured.Unstructured) {
+	id := NewWorkloadID(object)
+	cache.objects[id] = object
+	cache.names[id.withoutUID()] = id
+}
+
+// list returns a copy of the cached objects.
+func (cache *objectCache) list() []unstructured.Unstructured {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	objects := make([]unstructured.Unstructured, 0, len(cache.objects))
+	for _, object := range cache.objects {
+		objects = append(objects, object)
+	}
+	return objects
+}
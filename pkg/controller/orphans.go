@@ -0,0 +1,125 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DanglingOwner is an owner reference that doesn't lead to the object it names.
+type DanglingOwner struct {
+	// Object is the object that holds the owner reference.
+	Object corev1.ObjectReference
+	Owner  metav1.OwnerReference
+	// FoundUID is the UID of the object that has the owner's name, when it doesn't match Owner.UID.
+	FoundUID types.UID
+}
+
+// OrphanReport lists the objects whose ownership could not be resolved during discovery.
+type OrphanReport struct {
+	// MissingOwners lists pods whose owner reference points to an object that doesn't exist.
+	MissingOwners []DanglingOwner
+	// UIDMismatches lists objects whose owner exists by name, but with a different UID,
+	// usually because the owner was deleted and recreated.
+	UIDMismatches []DanglingOwner
+	// NakedPods lists pods without any owner.
+	NakedPods []corev1.ObjectReference
+	// OrphanedControllers lists owned objects other than pods, such as ReplicaSets or Jobs,
+	// whose owner doesn't exist, including those without pods.
+	OrphanedControllers []DanglingOwner
+}
+
+// GetOrphanReport runs discovery in the namespace, or the whole cluster if it is empty, and returns
+// DiscoveryResult.Orphans.
+func (client Client) GetOrphanReport(namespace string) (*OrphanReport, error) {
+	result, err := client.getAllTopControllers(namespaceFilter(namespace), false)
+	if err != nil {
+		return nil, err
+	}
+	return result.Orphans, nil
+}
+
+func objectReference(object unstructured.Unstructured) corev1.ObjectReference {
+	return corev1.ObjectReference{
+		APIVersion: object.GetAPIVersion(),
+		Kind:       object.GetKind(),
+		Namespace:  object.GetNamespace(),
+		Name:       object.GetName(),
+		UID:        object.GetUID(),
+	}
+}
+
+// orphanCollector builds an OrphanReport from the owner walks of pods and controllers.
+type orphanCollector struct {
+	report OrphanReport
+	seen   map[string]bool
+}
+
+func newOrphanCollector() *orphanCollector {
+	return &orphanCollector{seen: map[string]bool{}}
+}
+
+func (collector *orphanCollector) add(object unstructured.Unstructured, walk *ownerWalk) {
+	if object.GetKind() == "Pod" && len(object.GetOwnerReferences()) == 0 {
+		collector.report.NakedPods = append(collector.report.NakedPods, objectReference(object))
+	}
+	if walk.missing == nil || !collector.firstTime("missing", *walk.missing) {
+		return
+	}
+	if walk.missing.FoundUID != "" {
+		collector.report.UIDMismatches = append(collector.report.UIDMismatches, *walk.missing)
+	} else if walk.missing.Object.Kind == "Pod" {
+		collector.report.MissingOwners = append(collector.report.MissingOwners, *walk.missing)
+	} else {
+		collector.report.OrphanedControllers = append(collector.report.OrphanedControllers, *walk.missing)
+	}
+}
+
+func (collector *orphanCollector) firstTime(category string, dangling DanglingOwner) bool {
+	key := category + "/" + dangling.Object.Kind + "/" + dangling.Object.Namespace + "/" + dangling.Object.Name
+	if collector.seen[key] {
+		return false
+	}
+	collector.seen[key] = true
+	return true
+}
+
+func (collector *orphanCollector) finish() *OrphanReport {
+	report := collector.report
+	for _, list := range [][]DanglingOwner{report.MissingOwners, report.UIDMismatches, report.OrphanedControllers} {
+		sort.Slice(list, func(i, j int) bool {
+			return lessObjectReference(list[i].Object, list[j].Object)
+		})
+	}
+	sort.Slice(report.NakedPods, func(i, j int) bool {
+		return lessObjectReference(report.NakedPods[i], report.NakedPods[j])
+	})
+	return &report
+}
+
+func lessObjectReference(a, b corev1.ObjectReference) bool {
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestGetOrphanReport(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	ctx := context.TODO()
+	pods := client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("test")
+	replicaSets := client.Dynamic.Resource(schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "replicasets"}).Namespace("test")
+
+	naked := newPodWithOwners("naked")
+	_, err := pods.Create(ctx, &naked, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	orphanedRS := unstructured.Unstructured{}
+	orphanedRS.SetAPIVersion("apps/v1")
+	orphanedRS.SetKind("ReplicaSet")
+	orphanedRS.SetName("orphaned-rs")
+	orphanedRS.SetNamespace("test")
+	orphanedRS.SetUID("rs-uid")
+	orphanedRS.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "gone"}})
+	_, err = replicaSets.Create(ctx, &orphanedRS, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	for _, name := range []string{"orphaned-1", "orphaned-2"} {
+		pod := newPodWithOwners(name, metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "orphaned-rs", UID: "rs-uid"})
+		_, err = pods.Create(ctx, &pod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	emptyRS := orphanedRS.DeepCopy()
+	emptyRS.SetName("empty-rs")
+	emptyRS.SetUID("empty-uid")
+	emptyRS.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "gone-too"}})
+	_, err = replicaSets.Create(ctx, emptyRS, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	stale := newPodWithOwners("stale", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "orphaned-rs", UID: "old-uid"})
+	_, err = pods.Create(ctx, &stale, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	missing := newPodWithOwners("missing", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "missing-rs"})
+	_, err = pods.Create(ctx, &missing, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	report, err := client.GetOrphanReport("")
+	assert.NoError(t, err)
+	assert.Len(t, report.NakedPods, 1)
+	assert.Equal(t, "naked", report.NakedPods[0].Name)
+
+	assert.Len(t, report.MissingOwners, 2)
+	assert.Equal(t, "missing", report.MissingOwners[0].Object.Name)
+	assert.Equal(t, "missing-rs", report.MissingOwners[0].Owner.Name)
+	assert.Equal(t, "poddy-bad", report.MissingOwners[1].Object.Name)
+
+	assert.Len(t, report.UIDMismatches, 1)
+	assert.Equal(t, "stale", report.UIDMismatches[0].Object.Name)
+	assert.Equal(t, "rs-uid", string(report.UIDMismatches[0].FoundUID))
+
+	// The replica set without pods is found by walking the listed replica sets.
+	assert.Len(t, report.OrphanedControllers, 2)
+	assert.Equal(t, "empty-rs", report.OrphanedControllers[0].Object.Name)
+	assert.Equal(t, "gone-too", report.OrphanedControllers[0].Owner.Name)
+	assert.Equal(t, "orphaned-rs", report.OrphanedControllers[1].Object.Name)
+	assert.Equal(t, "gone", report.OrphanedControllers[1].Owner.Name)
+
+	// Discovery reports the same orphans along with the workloads.
+	result, err := client.DiscoverWorkloads(Filter{}, false)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.Workloads)
+	assert.Equal(t, report, result.Orphans)
+}
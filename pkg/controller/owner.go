@@ -0,0 +1,158 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fairwindsops/controller-utils/pkg/log"
+)
+
+// OwnerPolicy decides which owner is followed when an object has several owner references
+// and none of them is marked as the controller. An owner reference with controller set to
+// true is always followed when there is one.
+type OwnerPolicy string
+
+const (
+	// OwnerPolicyFirst follows the first owner reference. This is the default.
+	OwnerPolicyFirst OwnerPolicy = "First"
+	// OwnerPolicyError stops at the object and returns an ErrAmbiguousOwner error.
+	OwnerPolicyError OwnerPolicy = "Error"
+	// OwnerPolicyReportAll follows the first owner reference, and records every owner
+	// in Workload.AmbiguousOwners.
+	OwnerPolicyReportAll OwnerPolicy = "ReportAll"
+)
+
+// ErrAmbiguousOwner is returned with OwnerPolicyError when an object has several owners and none is the controller.
+var ErrAmbiguousOwner = errors.New("object has more than one owner and none is the controller")
+
+// AmbiguousOwnership describes an object with several owners, none of which is the controller.
+type AmbiguousOwnership struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Owners    []metav1.OwnerReference
+}
+
+// OwnerHop is one object on the way from an object up to its top controller.
+type OwnerHop struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	UID        types.UID
+}
+
+// OwnerChain is the ordered ancestry of an object. It starts with the object itself and ends with
+// its top controller, e.g. Pod, ReplicaSet, Deployment.
+type OwnerChain []OwnerHop
+
+// ownerWalk collects what was seen while walking from an object up to its top controller.
+type ownerWalk struct {
+	chain     OwnerChain
+	ambiguous []AmbiguousOwnership
+	// missing is set when the walk stopped because an owner doesn't exist. Its FoundUID is set
+	// when an object with the owner's name exists, but with another UID.
+	missing *DanglingOwner
+}
+
+// visited returns true if an object with the UID is already in the chain.
+func (walk *ownerWalk) visited(uid types.UID) bool {
+	for _, hop := range walk.chain {
+		if uid != "" && hop.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func (walk *ownerWalk) visit(object unstructured.Unstructured) {
+	walk.chain = append(walk.chain, OwnerHop{
+		APIVersion: object.GetAPIVersion(),
+		Kind:       object.GetKind(),
+		Name:       object.GetName(),
+		UID:        object.GetUID(),
+	})
+}
+
+// GetOwnerChain finds the highest level owner of whatever object is passed in, like GetTopController,
+// and also returns every object on the way there. If an owner can't be found, the chain ends with the
+// last object that was found.
+func (client Client) GetOwnerChain(unstructuredObject unstructured.Unstructured, objectCache map[string]unstructured.Unstructured) (unstructured.Unstructured, OwnerChain, error) {
+	walk := &ownerWalk{}
+	controller, err := client.getTopController(unstructuredObject, newObjectCache(objectCache), walk)
+	controller, err = client.fullTopController(unstructuredObject, controller, err)
+	return controller, walk.chain, err
+}
+
+func (client Client) ownerPolicy() OwnerPolicy {
+	if client.OwnerPolicy == "" {
+		return OwnerPolicyFirst
+	}
+	return client.OwnerPolicy
+}
+
+// selectOwner picks the owner reference to follow for an object that has at least one.
+func (client Client) selectOwner(object unstructured.Unstructured, walk *ownerWalk) (metav1.OwnerReference, error) {
+	owners := object.GetOwnerReferences()
+	for _, owner := range owners {
+		if owner.Controller != nil && *owner.Controller {
+			return owner, nil
+		}
+	}
+	if len(owners) == 1 {
+		return owners[0], nil
+	}
+	log.GetLogger().V(1).Info("Found more than one owner", object.GetName(), object.GetNamespace())
+	switch client.ownerPolicy() {
+	case OwnerPolicyError:
+		return metav1.OwnerReference{}, fmt.Errorf("%w: %s", ErrAmbiguousOwner, getControllerKey(object))
+	case OwnerPolicyReportAll:
+		walk.ambiguous = append(walk.ambiguous, AmbiguousOwnership{
+			Kind:      object.GetKind(),
+			Namespace: object.GetNamespace(),
+			Name:      object.GetName(),
+			Owners:    owners,
+		})
+	}
+	return owners[0], nil
+}
+
+// addWalk records the ambiguous objects found on the way from a pod to the top controller, and
+// the owner chain of the pod if pods are included.
+func (workload *Workload) addWalk(pod unstructured.Unstructured, walk *ownerWalk, includePods bool) {
+	if includePods {
+		if workload.OwnerChains == nil {
+			workload.OwnerChains = map[string]OwnerChain{}
+		}
+		workload.OwnerChains[pod.GetName()] = walk.chain
+	}
+	for _, found := range walk.ambiguous {
+		duplicate := false
+		for _, existing := range workload.AmbiguousOwners {
+			if existing.Kind == found.Kind && existing.Namespace == found.Namespace && existing.Name == found.Name {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			workload.AmbiguousOwners = append(workload.AmbiguousOwners, found)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newPodWithOwners(name string, owners ...metav1.OwnerReference) unstructured.Unstructured {
+	pod := unstructured.Unstructured{}
+	pod.SetAPIVersion("v1")
+	pod.SetKind("Pod")
+	pod.SetName(name)
+	pod.SetNamespace("test")
+	pod.SetOwnerReferences(owners)
+	return pod
+}
+
+func TestGetTopControllerPrefersController(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	isController := true
+	pod := newPodWithOwners("adopted",
+		metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "missing"},
+		metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs", Controller: &isController},
+	)
+	for _, policy := range []OwnerPolicy{"", OwnerPolicyFirst, OwnerPolicyError, OwnerPolicyReportAll} {
+		client.OwnerPolicy = policy
+		controller, err := client.GetTopController(pod, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, "dep", controller.GetName())
+	}
+}
+
+func TestGetTopControllerOwnerPolicy(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	pod := newPodWithOwners("shared",
+		metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"},
+		metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "missing"},
+	)
+	controller, err := client.GetTopController(pod, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "dep", controller.GetName())
+
+	client.OwnerPolicy = OwnerPolicyError
+	controller, err = client.GetTopController(pod, nil)
+	assert.ErrorIs(t, err, ErrAmbiguousOwner)
+	assert.Equal(t, "shared", controller.GetName())
+
+	_, err = client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("test").Create(context.TODO(), &pod, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	client.OwnerPolicy = OwnerPolicyReportAll
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	dep := findWorkload(workloads, "dep")
+	assert.Equal(t, 2, dep.PodCount)
+	assert.Equal(t, OwnerPolicyReportAll, dep.OwnerPolicy)
+	assert.Len(t, dep.AmbiguousOwners, 1)
+	assert.Equal(t, "shared", dep.AmbiguousOwners[0].Name)
+	assert.Len(t, dep.AmbiguousOwners[0].Owners, 2)
+
+	client.OwnerPolicy = ""
+	workloads, err = client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	dep = findWorkload(workloads, "dep")
+	assert.Equal(t, OwnerPolicyFirst, dep.OwnerPolicy)
+	assert.Empty(t, dep.AmbiguousOwners)
+}
+
+func TestGetOwnerChain(t *testing.T) {
+	client, pod, _, _, pod2 := setupFakeData(t)
+	controller, chain, err := client.GetOwnerChain(pod, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "dep", controller.GetName())
+	assert.Equal(t, OwnerChain{
+		{APIVersion: "apps/v1", Kind: "Pod", Name: "poddy"},
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"},
+		{APIVersion: "apps/v1", Kind: "Deployment", Name: "dep"},
+	}, chain)
+
+	controller, chain, err = client.GetOwnerChain(pod2, nil)
+	assert.Error(t, err)
+	assert.Equal(t, "poddy-bad", controller.GetName())
+	assert.Equal(t, OwnerChain{{APIVersion: "core/v1", Kind: "Pod", Name: "poddy-bad"}}, chain)
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.Empty(t, findWorkload(workloads, "dep").OwnerChains)
+
+	workloads, err = client.GetAllTopControllersWithPods("test")
+	assert.NoError(t, err)
+	dep := findWorkload(workloads, "dep")
+	assert.Len(t, dep.OwnerChains, 1)
+	assert.Equal(t, "rs", dep.OwnerChains["poddy"][1].Name)
+	assert.Empty(t, findWorkload(workloads, "dep-no-pods").OwnerChains)
+}
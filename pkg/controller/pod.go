@@ -22,16 +22,49 @@ import (
 	"github.com/samber/lo"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var podSpecFields = []string{"jobTemplate", "spec", "template"}
-var controllerValidKinds = []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "CronJob", "Job"}
 
 // GetPodMetadataAndSpec looks inside arbitrary YAML for a PodSpec and it's metadata
 func GetPodMetadataAndSpec(obj map[string]any) (*metav1.ObjectMeta, *corev1.PodSpec, error) {
+	return DefaultRegistry.GetPodMetadataAndSpec(obj)
+}
+
+// GetPodMetadataAndSpec looks inside arbitrary YAML for a PodSpec and it's metadata.
+// For registered kinds with a PodTemplatePath, the pod template is read from that path.
+// A *MalformedObjectError is returned if the pod template can't be decoded.
+func (registry *KindRegistry) GetPodMetadataAndSpec(obj map[string]any) (*metav1.ObjectMeta, *corev1.PodSpec, error) {
+	podMetadata, podSpec, err := registry.getPodMetadataAndSpec(obj)
+	if err != nil {
+		return nil, nil, newMalformedObjectError(obj, err)
+	}
+	return podMetadata, podSpec, nil
+}
+
+func (registry *KindRegistry) getPodMetadataAndSpec(obj map[string]any) (*metav1.ObjectMeta, *corev1.PodSpec, error) {
+	if template, ok := registry.getPodTemplate(obj); ok {
+		if spec, ok := template["spec"].(map[string]any); ok {
+			return getPodMetadataAndSpecRecursively(template, spec)
+		}
+	}
 	return getPodMetadataAndSpecRecursively(nil, obj)
 }
 
+func (registry *KindRegistry) getPodTemplate(obj map[string]any) (map[string]any, bool) {
+	info, ok := registry.lookupObject(obj)
+	if !ok || len(info.PodTemplatePath) == 0 {
+		return nil, false
+	}
+	template, found, err := unstructured.NestedFieldNoCopy(obj, info.PodTemplatePath...)
+	if err != nil || !found {
+		return nil, false
+	}
+	templateMap, ok := template.(map[string]any)
+	return templateMap, ok
+}
+
 func getPodMetadataAndSpecRecursively(parent map[string]any, obj map[string]any) (*metav1.ObjectMeta, *corev1.PodSpec, error) {
 	// TODO examine this for ways to make it more efficient.
 	for _, child := range podSpecFields {
@@ -76,19 +109,28 @@ func getMetadata(parent map[string]any) (*metav1.ObjectMeta, error) {
 	return &metadata, err
 }
 
-// ValidateIfControllerMatches checks if a child object is controlled by a parent object
+// ValidateIfControllerMatches checks if a child object is controlled by a parent object.
+// An *OwnerNotFoundError is returned if the first owner reference of the child doesn't refer to the controller,
+// and a *MappingNotFoundError if the controller isn't of a registered kind.
 func ValidateIfControllerMatches(child map[string]any, controller map[string]any) error {
+	notOwner := func(format string, args ...any) error {
+		owned := unstructured.Unstructured{Object: child}
+		return newOwnerNotFoundError(owned, owned.GetOwnerReferences()[0], fmt.Errorf(format, args...))
+	}
 	if child["metadata"].(map[string]any)["ownerReferences"].([]any)[0].(map[string]any)["uid"] != controller["metadata"].(map[string]any)["uid"] {
-		return fmt.Errorf("controller does not match ownerReference uid")
+		return notOwner("controller does not match ownerReference uid")
 	}
 	if child["metadata"].(map[string]any)["namespace"].(string) != controller["metadata"].(map[string]any)["namespace"].(string) {
-		return fmt.Errorf("controller namespace %s does not match ownerReference namespace %s", controller["metadata"].(map[string]any)["namespace"], child["metadata"].(map[string]any)["ownerReferences"].([]any)[0].(map[string]any)["namespace"])
+		return notOwner("controller namespace %s does not match ownerReference namespace %s", controller["metadata"].(map[string]any)["namespace"], child["metadata"].(map[string]any)["ownerReferences"].([]any)[0].(map[string]any)["namespace"])
 	}
 	if child["metadata"].(map[string]any)["ownerReferences"].([]any)[0].(map[string]any)["name"].(string) != controller["metadata"].(map[string]any)["name"].(string) {
-		return fmt.Errorf("controller name %s does not match ownerReference name %s", controller["metadata"].(map[string]any)["name"], child["metadata"].(map[string]any)["ownerReferences"].([]any)[0].(map[string]any)["name"])
+		return notOwner("controller name %s does not match ownerReference name %s", controller["metadata"].(map[string]any)["name"], child["metadata"].(map[string]any)["ownerReferences"].([]any)[0].(map[string]any)["name"])
 	}
-	if !lo.Contains(controllerValidKinds, controller["kind"].(string)) {
-		return fmt.Errorf("controller kind %s is not a valid controller kind", controller["kind"].(string))
+	if _, ok := DefaultRegistry.lookupObject(controller); !ok {
+		return &MappingNotFoundError{
+			GroupVersionKind: (&unstructured.Unstructured{Object: controller}).GroupVersionKind(),
+			Err:              fmt.Errorf("controller kind %s is not a valid controller kind", controller["kind"].(string)),
+		}
 	}
 	childContainers := getChildContainers(child)
 	controllerContainers := getControllerContainers(controller)
@@ -127,6 +169,13 @@ func getChildContainers(child map[string]any) []any {
 }
 
 func getControllerContainers(controller map[string]any) []any {
+	if template, ok := DefaultRegistry.getPodTemplate(controller); ok {
+		if containers, found, err := unstructured.NestedFieldNoCopy(template, "spec", "containers"); err == nil && found {
+			if containerList, ok := containers.([]any); ok {
+				return containerList
+			}
+		}
+	}
 	if _, ok := controller["spec"].(map[string]any)["jobTemplate"]; ok {
 		return controller["spec"].(map[string]any)["jobTemplate"].(map[string]any)["spec"].(map[string]any)["template"].(map[string]any)["spec"].(map[string]any)["containers"].([]any)
 	}
@@ -71,7 +71,7 @@ func TestGetPodSpec(t *testing.T) {
 	assert.Len(t, podSpec.Containers, 1)
 }
 
-func readFile(t *testing.T, file string) map[string]any {
+func readFile(t testing.TB, file string) map[string]any {
 	contents, err := os.ReadFile(file)
 	assert.NoError(t, err)
 	var object map[string]any
@@ -84,8 +84,13 @@ func TestValidateIfControllerMatches(t *testing.T) {
 	err := ValidateIfControllerMatches(readFile(t, "./testdata/pod1.json"), readFile(t, "./testdata/controller1.json"))
 	assert.NoError(t, err)
 	err = ValidateIfControllerMatches(readFile(t, "./testdata/pod2.json"), readFile(t, "./testdata/controller1.json"))
-	assert.Error(t, err)
-	assert.Equal(t, "controller name trivy does not match ownerReference name invalid2", err.Error())
+	assert.ErrorIs(t, err, ErrOwnerNotFound)
+	assert.Equal(t, "owner Job insights-agent/invalid2 of Pod/insights-agent/trivy-fnsld not found: controller name trivy does not match ownerReference name invalid2", err.Error())
+	unknown := readFile(t, "./testdata/controller1.json")
+	unknown["kind"] = "ScaledJob"
+	err = ValidateIfControllerMatches(readFile(t, "./testdata/pod1.json"), unknown)
+	assert.ErrorIs(t, err, ErrMappingNotFound)
+	assert.Contains(t, err.Error(), "controller kind ScaledJob is not a valid controller kind")
 	err = ValidateIfControllerMatches(readFile(t, "./testdata/pod3.json"), readFile(t, "./testdata/controller1.json"))
 	assert.Error(t, err)
 	assert.Equal(t, "controller does not match child containers names", err.Error())
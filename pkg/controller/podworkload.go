@@ -0,0 +1,116 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// GetWorkloadForPod finds the workload of a single pod by getting each owner by name, so it makes one
+// API call per owner instead of listing whole kinds, e.g. in an admission webhook. The UID of every
+// owner is checked against the owner reference, and an owner that has been deleted and created again
+// is reported as an *OwnerNotFoundError. Owner references that lead back to an object that was already
+// visited return ErrOwnerCycle. The workload only counts this pod.
+func (client Client) GetWorkloadForPod(ctx context.Context, pod unstructured.Unstructured) (*Workload, error) {
+	client.Context = ctx
+	walk := &ownerWalk{}
+	controller, err := client.getTopControllerByName(pod, walk)
+	if err != nil {
+		return nil, err
+	}
+	workload := &Workload{
+		ID:            NewWorkloadID(controller),
+		TopController: controller,
+		Pods:          []unstructured.Unstructured{pod},
+		PodCount:      1,
+		OwnerPolicy:   client.ownerPolicy(),
+		typed:         newTypedObjects(),
+	}
+	workload.PodMetadata, workload.PodSpec, err = client.registry().GetPodMetadataAndSpec(controller.UnstructuredContent())
+	if err == nil && workload.PodSpec == nil {
+		workload.PodMetadata, workload.PodSpec, err = client.registry().GetPodMetadataAndSpec(pod.UnstructuredContent())
+	}
+	if err != nil {
+		return nil, err
+	}
+	workload.addWalk(pod, walk, true)
+	if getPodStatus(pod) == podStatusRunning {
+		workload.RunningPodCount++
+	}
+	workload.PodStatus.add(pod)
+	workload.Restarts.add(pod)
+	workload.Distribution.add(pod, nil)
+	workload.Health = client.registry().workloadHealth(controller)
+	workload.setResources()
+	return workload, nil
+}
+
+// getTopControllerByName follows the same owner references as getTopController, but gets each owner by name.
+func (client Client) getTopControllerByName(object unstructured.Unstructured, walk *ownerWalk) (unstructured.Unstructured, error) {
+	for {
+		if err := client.context().Err(); err != nil {
+			return object, err
+		}
+		if walk.visited(object.GetUID()) {
+			return object, fmt.Errorf("%w at %s", ErrOwnerCycle, getControllerKey(object))
+		}
+		walk.visit(object)
+		if len(object.GetOwnerReferences()) == 0 {
+			return object, nil
+		}
+		owner, err := client.selectOwner(object, walk)
+		if err != nil {
+			return object, err
+		}
+		if owner.Kind == "Node" {
+			// Static pods are their own controller.
+			return object, nil
+		}
+		parent, err := client.getOwner(object, owner)
+		if err != nil {
+			return object, err
+		}
+		object = parent
+	}
+}
+
+// getOwner gets the owner an object refers to, and checks that it has the expected UID.
+func (client Client) getOwner(object unstructured.Unstructured, owner metav1.OwnerReference) (unstructured.Unstructured, error) {
+	fqKind := schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind)
+	mapping, err := client.restMapping(fqKind)
+	if err != nil {
+		return object, newOwnerNotFoundError(object, owner, err)
+	}
+	parent, err := client.Dynamic.Resource(mapping.Resource).Namespace(object.GetNamespace()).Get(client.context(), owner.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return object, newOwnerNotFoundError(object, owner, nil)
+	}
+	if apierrors.IsForbidden(err) {
+		return object, &ForbiddenError{GroupVersionKind: fqKind, Namespace: object.GetNamespace(), Err: err}
+	}
+	if err != nil {
+		return object, fmt.Errorf("getting %s %s: %w", owner.Kind, objectName(object.GetNamespace(), owner.Name), err)
+	}
+	if owner.UID != "" && parent.GetUID() != owner.UID {
+		return object, newOwnerNotFoundError(object, owner, errUIDMismatch(owner.UID, parent.GetUID()))
+	}
+	return *parent, nil
+}
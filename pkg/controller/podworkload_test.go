@@ -0,0 +1,98 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestGetWorkloadForPod(t *testing.T) {
+	client, pod, rs, _, pod2 := setupFakeData(t)
+	dynamic := client.Dynamic.(*fake.FakeDynamicClient)
+	deployment := readFile(t, "./testdata/deployment.json")
+	dep, err := dynamic.Resource(deploymentsResource).Namespace("test").Get(context.TODO(), "dep", metav1.GetOptions{})
+	assert.NoError(t, err)
+	dep.Object["spec"] = deployment["spec"]
+	dep.SetUID("dep-uid")
+	_, err = dynamic.Resource(deploymentsResource).Namespace("test").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	rs.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "dep", UID: "dep-uid"}})
+	_, err = dynamic.Resource(replicaSetsResource).Namespace("test").Update(context.TODO(), &rs, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	dynamic.ClearActions()
+	workload, err := client.GetWorkloadForPod(context.TODO(), pod)
+	assert.NoError(t, err)
+	// One get per owner, and nothing is listed.
+	actions := dynamic.Actions()
+	assert.Len(t, actions, 2)
+	for _, action := range actions {
+		assert.Equal(t, "get", action.GetVerb())
+	}
+	assert.Equal(t, "replicasets", actions[0].GetResource().Resource)
+	assert.Equal(t, "deployments", actions[1].GetResource().Resource)
+	assert.Equal(t, WorkloadID{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "dep", UID: "dep-uid"}, workload.ID)
+	assert.Equal(t, "container", workload.PodSpec.Containers[0].Name)
+	assert.NotNil(t, workload.PodMetadata)
+	assert.Equal(t, 1, workload.PodCount)
+	assert.Len(t, workload.Pods, 1)
+	assert.Len(t, workload.OwnerChains["poddy"], 3)
+	assert.NotNil(t, workload.Health)
+
+	rs.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "dep", UID: "recreated"}})
+	_, err = dynamic.Resource(replicaSetsResource).Namespace("test").Update(context.TODO(), &rs, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	dynamic.ClearActions()
+	_, err = client.GetWorkloadForPod(context.TODO(), pod)
+	assert.ErrorIs(t, err, ErrOwnerNotFound)
+	assert.Len(t, dynamic.Actions(), 2)
+
+	dynamic.ClearActions()
+	_, err = client.GetWorkloadForPod(context.TODO(), pod2)
+	assert.ErrorIs(t, err, ErrOwnerNotFound)
+	assert.ErrorIs(t, err, ErrMappingNotFound)
+	assert.Empty(t, dynamic.Actions())
+
+	standalone := newPodWithOwners("standalone")
+	dynamic.ClearActions()
+	workload, err = client.GetWorkloadForPod(context.TODO(), standalone)
+	assert.NoError(t, err)
+	assert.Equal(t, "standalone", workload.TopController.GetName())
+	assert.Empty(t, dynamic.Actions())
+
+	// A deployment owned by its own replica set is reported instead of walked forever.
+	rs.SetUID("rs-uid")
+	rs.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "dep", UID: "dep-uid"}})
+	_, err = dynamic.Resource(replicaSetsResource).Namespace("test").Update(context.TODO(), &rs, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	dep.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs", UID: "rs-uid"}})
+	_, err = dynamic.Resource(deploymentsResource).Namespace("test").Update(context.TODO(), dep, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	dynamic.ClearActions()
+	_, err = client.GetWorkloadForPod(context.TODO(), pod)
+	assert.ErrorIs(t, err, ErrOwnerCycle)
+	assert.EqualError(t, err, "owner references form a cycle at ReplicaSet/test/rs")
+	assert.Len(t, dynamic.Actions(), 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.GetWorkloadForPod(ctx, pod)
+	assert.ErrorIs(t, err, context.Canceled)
+}
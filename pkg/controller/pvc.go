@@ -0,0 +1,198 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/fairwindsops/controller-utils/pkg/log"
+)
+
+// legacyStorageClassAnnotation was used to request a storage class before spec.storageClassName.
+const legacyStorageClassAnnotation = "volume.beta.kubernetes.io/storage-class"
+
+// PersistentVolumeClaimUsage links a PVC to the workloads that use it.
+type PersistentVolumeClaimUsage struct {
+	Claim unstructured.Unstructured
+	// Workloads are the workloads that mount the claim, sorted by ID.
+	Workloads []WorkloadID
+	// VolumeName is the PersistentVolume the claim is bound to, if any.
+	VolumeName string
+	// StorageClassName is the storage class requested by the claim, if any.
+	StorageClassName string
+}
+
+// PersistentVolumeClaimReport maps every PVC to the workloads that use it.
+type PersistentVolumeClaimReport struct {
+	Claims []PersistentVolumeClaimUsage
+	// Unmounted lists claims that no workload uses.
+	Unmounted []corev1.ObjectReference
+	// Shared lists claims used by more than one workload.
+	Shared []corev1.ObjectReference
+}
+
+// GetPersistentVolumeClaimUsage maps each PVC to the workloads that use it. A workload uses a claim that is
+// mounted by its pods or its pod template, a claim created from the volumeClaimTemplates of its StatefulSet, named
+// <template>-<statefulset>-<ordinal>, or a generic ephemeral volume claim of one of its pods. StatefulSets owned
+// by another controller, such as an operator's custom resource, count towards that controller's workload.
+// Claims and workloads are only listed in the namespace, unless it is empty.
+func (client Client) GetPersistentVolumeClaimUsage(namespace string) (*PersistentVolumeClaimReport, error) {
+	claims, err := client.GetAllPersistentVolumeClaims(namespace)
+	if err != nil {
+		return nil, err
+	}
+	workloads, err := client.getWorkloads(namespaceFilter(namespace), true)
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[types.NamespacedName]map[WorkloadID]bool{}
+	use := func(namespace, claimName string, workload WorkloadID) {
+		key := types.NamespacedName{Namespace: namespace, Name: claimName}
+		if users[key] == nil {
+			users[key] = map[WorkloadID]bool{}
+		}
+		users[key][workload] = true
+	}
+	podWorkloads := map[types.NamespacedName]WorkloadID{}
+	for _, workload := range workloads {
+		namespace := workload.TopController.GetNamespace()
+		if workload.PodSpec != nil {
+			for _, claimName := range claimNames("", workload.PodSpec.Volumes) {
+				use(namespace, claimName, workload.ID)
+			}
+		}
+		for _, pod := range workload.Pods {
+			podWorkloads[types.NamespacedName{Namespace: pod.GetNamespace(), Name: pod.GetName()}] = workload.ID
+			for _, claimName := range claimNames(pod.GetName(), podVolumes(pod)) {
+				use(pod.GetNamespace(), claimName, workload.ID)
+			}
+		}
+	}
+	// StatefulSets are listed rather than taken from the workloads, since they may be owned by another
+	// controller, such as an operator's custom resource, and have no pods once they are scaled down.
+	statefulSets, err := client.listKind(schema.FromAPIVersionAndKind("apps/v1", "StatefulSet"), namespace, metav1.ListOptions{})
+	if err != nil && !errors.Is(err, ErrMappingNotFound) {
+		return nil, err
+	}
+	objectCache := newObjectCache(nil)
+	for _, statefulSet := range statefulSets {
+		controller, err := client.getTopController(statefulSet, objectCache, &ownerWalk{})
+		if ctxErr := client.context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil {
+			log.GetLogger().V(1).Info("Unable to find the top level controller for this StatefulSet", statefulSet.GetName(), statefulSet.GetNamespace())
+		}
+		for _, claim := range claims {
+			if claim.GetNamespace() == statefulSet.GetNamespace() && isVolumeClaimTemplateClaim(statefulSet, claim.GetName()) {
+				use(claim.GetNamespace(), claim.GetName(), NewWorkloadID(controller))
+			}
+		}
+	}
+
+	report := &PersistentVolumeClaimReport{}
+	for _, claim := range claims {
+		for _, owner := range claim.GetOwnerReferences() {
+			if owner.Kind != "Pod" {
+				continue
+			}
+			if workload, ok := podWorkloads[types.NamespacedName{Namespace: claim.GetNamespace(), Name: owner.Name}]; ok {
+				use(claim.GetNamespace(), claim.GetName(), workload)
+			}
+		}
+		usage := PersistentVolumeClaimUsage{Claim: claim}
+		usage.VolumeName, _, _ = unstructured.NestedString(claim.Object, "spec", "volumeName")
+		usage.StorageClassName, _, _ = unstructured.NestedString(claim.Object, "spec", "storageClassName")
+		if usage.StorageClassName == "" {
+			usage.StorageClassName = claim.GetAnnotations()[legacyStorageClassAnnotation]
+		}
+		for workload := range users[types.NamespacedName{Namespace: claim.GetNamespace(), Name: claim.GetName()}] {
+			usage.Workloads = append(usage.Workloads, workload)
+		}
+		sort.Slice(usage.Workloads, func(i, j int) bool {
+			return usage.Workloads[i].String() < usage.Workloads[j].String()
+		})
+		switch {
+		case len(usage.Workloads) == 0:
+			report.Unmounted = append(report.Unmounted, objectReference(claim))
+		case len(usage.Workloads) > 1:
+			report.Shared = append(report.Shared, objectReference(claim))
+		}
+		report.Claims = append(report.Claims, usage)
+	}
+	return report, nil
+}
+
+// podVolumes decodes the volumes of a pod. Malformed volumes are skipped.
+func podVolumes(pod unstructured.Unstructured) []corev1.Volume {
+	volumes, found, err := unstructured.NestedFieldNoCopy(pod.Object, "spec", "volumes")
+	if err != nil || !found {
+		return nil
+	}
+	var spec corev1.PodSpec
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(map[string]any{"volumes": volumes}, &spec)
+	if err != nil {
+		log.GetLogger().V(1).Info("Unable to decode the volumes of this pod", pod.GetName(), pod.GetNamespace())
+		return nil
+	}
+	return spec.Volumes
+}
+
+// claimNames returns the names of the claims mounted by the volumes. Generic ephemeral volumes
+// are named <pod>-<volume>, so they are only returned when the pod name is known.
+func claimNames(podName string, volumes []corev1.Volume) []string {
+	var names []string
+	for _, volume := range volumes {
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			names = append(names, volume.PersistentVolumeClaim.ClaimName)
+		case volume.Ephemeral != nil && podName != "":
+			names = append(names, podName+"-"+volume.Name)
+		}
+	}
+	return names
+}
+
+// isVolumeClaimTemplateClaim reports whether a claim was created from one of the volumeClaimTemplates
+// of a StatefulSet, whatever the current number of replicas.
+func isVolumeClaimTemplateClaim(statefulSet unstructured.Unstructured, claimName string) bool {
+	templates, _, _ := unstructured.NestedFieldNoCopy(statefulSet.Object, "spec", "volumeClaimTemplates")
+	templateList, _ := templates.([]any)
+	for _, template := range templateList {
+		templateMap, ok := template.(map[string]any)
+		if !ok {
+			continue
+		}
+		templateName, _, _ := unstructured.NestedString(templateMap, "metadata", "name")
+		if templateName == "" {
+			continue
+		}
+		ordinal, ok := strings.CutPrefix(claimName, templateName+"-"+statefulSet.GetName()+"-")
+		if ok && ordinal != "" && strings.Trim(ordinal, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newStorageClient(t *testing.T, objects ...runtime.Object) Client {
+	return newFakeClient(t, []schema.GroupVersionKind{
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+		{Version: "v1", Kind: "Pod"},
+		{Version: "v1", Kind: "PersistentVolumeClaim"},
+		{Group: "monitoring.coreos.com", Version: "v1", Kind: "Prometheus"},
+	}, map[schema.GroupVersionResource]string{
+		{Group: "apps", Version: "v1", Resource: "deployments"}:                   "DeploymentList",
+		{Group: "apps", Version: "v1", Resource: "statefulsets"}:                  "StatefulSetList",
+		{Version: "v1", Resource: "pods"}:                                         "PodList",
+		{Version: "v1", Resource: "persistentvolumeclaims"}:                       "PersistentVolumeClaimList",
+		{Group: "monitoring.coreos.com", Version: "v1", Resource: "prometheuses"}: "PrometheusList",
+		{Version: "v1", Resource: "namespaces"}:                                   "NamespaceList",
+	}, objects...)
+}
+
+func newClaim(name string, spec map[string]any) *unstructured.Unstructured {
+	claim := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+	claim.SetAPIVersion("v1")
+	claim.SetKind("PersistentVolumeClaim")
+	claim.SetName(name)
+	claim.SetNamespace("test")
+	return claim
+}
+
+func claimVolume(claimName string) map[string]any {
+	return map[string]any{"name": "volume", "persistentVolumeClaim": map[string]any{"claimName": claimName}}
+}
+
+func newDeploymentWithVolumes(name string, volumes ...any) *unstructured.Unstructured {
+	deployment := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "app"}},
+			"volumes":    volumes,
+		}}},
+	}}
+	deployment.SetAPIVersion("apps/v1")
+	deployment.SetKind("Deployment")
+	deployment.SetName(name)
+	deployment.SetNamespace("test")
+	return deployment
+}
+
+func TestGetPersistentVolumeClaimUsage(t *testing.T) {
+	statefulSet := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"template": map[string]any{"spec": map[string]any{"containers": []any{map[string]any{"name": "db"}}}},
+			"volumeClaimTemplates": []any{
+				map[string]any{"metadata": map[string]any{"name": "data"}},
+			},
+		},
+	}}
+	statefulSet.SetAPIVersion("apps/v1")
+	statefulSet.SetKind("StatefulSet")
+	statefulSet.SetName("db")
+	statefulSet.SetNamespace("test")
+	statefulSet.SetUID("db-uid")
+	isController := true
+	dbPod := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{
+		"volumes": []any{map[string]any{"name": "data", "persistentVolumeClaim": map[string]any{"claimName": "data-db-0"}}},
+	}}}
+	dbPod.SetAPIVersion("v1")
+	dbPod.SetKind("Pod")
+	dbPod.SetName("db-0")
+	dbPod.SetNamespace("test")
+	dbPod.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", UID: "db-uid", Controller: &isController}})
+	scratchPod := &unstructured.Unstructured{Object: map[string]any{"spec": map[string]any{
+		"volumes": []any{map[string]any{"name": "cache", "ephemeral": map[string]any{}}},
+	}}}
+	scratchPod.SetAPIVersion("v1")
+	scratchPod.SetKind("Pod")
+	scratchPod.SetName("scratch")
+	scratchPod.SetNamespace("test")
+	scratchPod.SetUID("scratch-uid")
+	ephemeral := newClaim("scratch-cache", map[string]any{})
+	ephemeral.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "v1", Kind: "Pod", Name: "scratch", UID: "scratch-uid", Controller: &isController}})
+	legacy := newClaim("legacy", map[string]any{})
+	legacy.SetAnnotations(map[string]string{legacyStorageClassAnnotation: "slow"})
+
+	// The StatefulSet of an operator's custom resource is scaled down, so it has no pods.
+	prometheus := &unstructured.Unstructured{Object: map[string]any{}}
+	prometheus.SetAPIVersion("monitoring.coreos.com/v1")
+	prometheus.SetKind("Prometheus")
+	prometheus.SetName("main")
+	prometheus.SetNamespace("test")
+	prometheus.SetUID("main-uid")
+	operated := statefulSet.DeepCopy()
+	operated.SetName("prometheus-main")
+	operated.SetUID("prometheus-main-uid")
+	operated.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "monitoring.coreos.com/v1", Kind: "Prometheus", Name: "main", UID: "main-uid", Controller: &isController}})
+
+	client := newStorageClient(t,
+		statefulSet, dbPod, scratchPod, ephemeral, legacy, prometheus, operated,
+		newClaim("data-prometheus-main-0", map[string]any{}),
+		newDeploymentWithVolumes("web", claimVolume("shared")),
+		newDeploymentWithVolumes("api", claimVolume("shared")),
+		newClaim("data-db-0", map[string]any{"volumeName": "pv-0", "storageClassName": "fast"}),
+		newClaim("data-db-1", map[string]any{}),
+		newClaim("data-dbx-0", map[string]any{}),
+		newClaim("shared", map[string]any{}),
+	)
+	report, err := client.GetPersistentVolumeClaimUsage("test")
+	assert.NoError(t, err)
+
+	usages := map[string]PersistentVolumeClaimUsage{}
+	for _, usage := range report.Claims {
+		usages[usage.Claim.GetName()] = usage
+	}
+	assert.Len(t, usages, 7)
+	assert.Equal(t, []WorkloadID{{Group: "monitoring.coreos.com", Kind: "Prometheus", Namespace: "test", Name: "main", UID: "main-uid"}}, usages["data-prometheus-main-0"].Workloads)
+	db := WorkloadID{Group: "apps", Kind: "StatefulSet", Namespace: "test", Name: "db", UID: "db-uid"}
+	assert.Equal(t, []WorkloadID{db}, usages["data-db-0"].Workloads)
+	assert.Equal(t, "pv-0", usages["data-db-0"].VolumeName)
+	assert.Equal(t, "fast", usages["data-db-0"].StorageClassName)
+	assert.Equal(t, []WorkloadID{db}, usages["data-db-1"].Workloads)
+	assert.Empty(t, usages["data-dbx-0"].Workloads)
+	assert.Equal(t, "slow", usages["legacy"].StorageClassName)
+	assert.Equal(t, []WorkloadID{{Kind: "Pod", Namespace: "test", Name: "scratch", UID: "scratch-uid"}}, usages["scratch-cache"].Workloads)
+	assert.Equal(t, []string{"api", "web"}, []string{usages["shared"].Workloads[0].Name, usages["shared"].Workloads[1].Name})
+
+	unmounted := []string{}
+	for _, claim := range report.Unmounted {
+		unmounted = append(unmounted, claim.Name)
+	}
+	assert.ElementsMatch(t, []string{"data-dbx-0", "legacy"}, unmounted)
+	assert.Len(t, report.Shared, 1)
+	assert.Equal(t, "shared", report.Shared[0].Name)
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KindInfo describes a kind of object that controls pods.
+type KindInfo struct {
+	GroupVersionKind schema.GroupVersionKind
+	// TopLevel kinds are listed up front, so that objects of this kind without owners
+	// are returned as workloads even when they have no pods.
+	TopLevel bool
+	// PodTemplatePath is the path to the pod template, the object holding the pod's metadata and spec.
+	// For a Deployment this is ["spec", "template"]. If it is empty, the pod spec is searched for.
+	PodTemplatePath []string
+	// Intermediate kinds are usually owned by another controller, like ReplicaSets by Deployments.
+	// With Client.Metadata they are listed as metadata only, and the few without an owner are fetched in full.
+	Intermediate bool
+	// Health computes the rollout status of objects of this kind. Workload.Health is left empty if it is not set.
+	Health HealthFunc
+}
+
+// KindRegistry holds the kinds that are treated as workload controllers.
+type KindRegistry struct {
+	mu    sync.RWMutex
+	kinds []KindInfo
+}
+
+// DefaultRegistry is used by clients that don't set their own Registry, and by the package level functions.
+// It contains Deployments, ReplicaSets, CronJobs, Jobs, DaemonSets and StatefulSets.
+var DefaultRegistry = NewKindRegistry()
+
+// NewKindRegistry returns a registry that contains the built-in controller kinds.
+func NewKindRegistry() *KindRegistry {
+	return &KindRegistry{kinds: []KindInfo{{
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		TopLevel:         true,
+		PodTemplatePath:  []string{"spec", "template"},
+		Health:           deploymentHealth,
+	}, {
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+		TopLevel:         true,
+		Intermediate:     true,
+		PodTemplatePath:  []string{"spec", "template"},
+		Health:           replicaSetHealth,
+	}, {
+		GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+		TopLevel:         true,
+		PodTemplatePath:  []string{"spec", "jobTemplate", "spec", "template"},
+		Health:           cronJobHealth,
+	}, {
+		GroupVersionKind: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"},
+		TopLevel:         true,
+		Intermediate:     true,
+		PodTemplatePath:  []string{"spec", "template"},
+		Health:           jobHealth,
+	}, {
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+		TopLevel:         true,
+		PodTemplatePath:  []string{"spec", "template"},
+		Health:           daemonSetHealth,
+	}, {
+		GroupVersionKind: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+		TopLevel:         true,
+		PodTemplatePath:  []string{"spec", "template"},
+		Health:           statefulSetHealth,
+	}}}
+}
+
+// RegisterKind adds a kind to the DefaultRegistry.
+func RegisterKind(info KindInfo) {
+	DefaultRegistry.Register(info)
+}
+
+// Register adds a kind to the registry, replacing any kind with the same group and kind.
+func (registry *KindRegistry) Register(info KindInfo) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for idx, existing := range registry.kinds {
+		if existing.GroupVersionKind.GroupKind() == info.GroupVersionKind.GroupKind() {
+			registry.kinds[idx] = info
+			return
+		}
+	}
+	registry.kinds = append(registry.kinds, info)
+}
+
+// Kinds returns all registered kinds, in the order they were registered.
+func (registry *KindRegistry) Kinds() []KindInfo {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return append([]KindInfo{}, registry.kinds...)
+}
+
+// Lookup returns the registered kind with the given group and kind.
+func (registry *KindRegistry) Lookup(groupKind schema.GroupKind) (KindInfo, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, info := range registry.kinds {
+		if info.GroupVersionKind.GroupKind() == groupKind {
+			return info, true
+		}
+	}
+	return KindInfo{}, false
+}
+
+// lookupObject finds the registered kind of a raw object. If the object has no apiVersion,
+// only its kind is compared.
+func (registry *KindRegistry) lookupObject(obj map[string]any) (KindInfo, bool) {
+	kind, _ := obj["kind"].(string)
+	apiVersion, _ := obj["apiVersion"].(string)
+	if kind == "" {
+		return KindInfo{}, false
+	}
+	if apiVersion != "" {
+		return registry.Lookup(schema.FromAPIVersionAndKind(apiVersion, kind).GroupKind())
+	}
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	for _, info := range registry.kinds {
+		if info.GroupVersionKind.Kind == kind {
+			return info, true
+		}
+	}
+	return KindInfo{}, false
+}
+
+func (client Client) registry() *KindRegistry {
+	if client.Registry != nil {
+		return client.Registry
+	}
+	return DefaultRegistry
+}
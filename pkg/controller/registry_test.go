@@ -0,0 +1,126 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+var scaledJobKind = schema.GroupVersionKind{Group: "keda.sh", Version: "v1alpha1", Kind: "ScaledJob"}
+
+func newScaledJob(name string) unstructured.Unstructured {
+	return unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "keda.sh/v1alpha1",
+			"kind":       "ScaledJob",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "test",
+			},
+			"spec": map[string]interface{}{
+				"jobTargetRef": map[string]interface{}{
+					"template": map[string]interface{}{
+						"metadata": map[string]interface{}{
+							"labels": map[string]interface{}{"app": name},
+						},
+						"spec": map[string]interface{}{
+							"containers": []interface{}{
+								map[string]interface{}{"name": "worker", "image": "worker:1"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestKindRegistry(t *testing.T) {
+	registry := NewKindRegistry()
+	info, ok := registry.Lookup(schema.GroupKind{Group: "apps", Kind: "Deployment"})
+	assert.True(t, ok)
+	assert.True(t, info.TopLevel)
+	_, ok = registry.Lookup(scaledJobKind.GroupKind())
+	assert.False(t, ok)
+
+	scaledJob := newScaledJob("scaled")
+	_, podSpec, err := registry.GetPodMetadataAndSpec(scaledJob.Object)
+	assert.NoError(t, err)
+	assert.Nil(t, podSpec)
+
+	registry.Register(KindInfo{
+		GroupVersionKind: scaledJobKind,
+		TopLevel:         true,
+		PodTemplatePath:  []string{"spec", "jobTargetRef", "template"},
+	})
+	podMetadata, podSpec, err := registry.GetPodMetadataAndSpec(scaledJob.Object)
+	assert.NoError(t, err)
+	assert.NotNil(t, podSpec)
+	assert.Equal(t, "worker", podSpec.Containers[0].Name)
+	assert.Equal(t, map[string]string{"app": "scaled"}, podMetadata.Labels)
+
+	registry.Register(KindInfo{GroupVersionKind: scaledJobKind})
+	assert.Len(t, registry.Kinds(), 7)
+	info, _ = registry.Lookup(scaledJobKind.GroupKind())
+	assert.False(t, info.TopLevel)
+
+	// The default registry is left alone.
+	_, ok = DefaultRegistry.Lookup(scaledJobKind.GroupKind())
+	assert.False(t, ok)
+}
+
+func TestGetAllTopControllersWithRegisteredKind(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	restMapper := client.RESTMapper.(*meta.DefaultRESTMapper)
+	restMapper.Add(scaledJobKind, meta.RESTScopeNamespace)
+	scaledJobs := schema.GroupVersionResource{Group: "keda.sh", Version: "v1alpha1", Resource: "scaledjobs"}
+	dynamic := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "apps", Version: "v1", Resource: "replicasets"}: "ReplicaSetList",
+			{Group: "apps", Version: "v1", Resource: "deployments"}: "DeploymentList",
+			{Group: "", Version: "v1", Resource: "pods"}:            "PodsList",
+			scaledJobs: "ScaledJobList",
+		},
+	)
+	scaledJob := newScaledJob("scaled")
+	_, err := dynamic.Resource(scaledJobs).Namespace("test").Create(context.TODO(), &scaledJob, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	client.Dynamic = dynamic
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 0)
+
+	client.Registry = NewKindRegistry()
+	client.Registry.Register(KindInfo{
+		GroupVersionKind: scaledJobKind,
+		TopLevel:         true,
+		PodTemplatePath:  []string{"spec", "jobTargetRef", "template"},
+	})
+	workloads, err = client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 1)
+	assert.Equal(t, "ScaledJob", workloads[0].TopController.GetKind())
+	assert.Equal(t, "worker", workloads[0].PodSpec.Containers[0].Name)
+}
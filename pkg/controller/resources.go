@@ -0,0 +1,131 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// WorkloadResources are the effective resource requests and limits of a workload's pods.
+type WorkloadResources struct {
+	// PodRequests and PodLimits are the effective requests and limits of a single pod, see GetPodResources.
+	PodRequests corev1.ResourceList
+	PodLimits   corev1.ResourceList
+	// DesiredRequests and DesiredLimits are multiplied by Health.Desired. They are nil if Health is nil.
+	DesiredRequests corev1.ResourceList
+	DesiredLimits   corev1.ResourceList
+	// RunningRequests and RunningLimits are multiplied by RunningPodCount.
+	RunningRequests corev1.ResourceList
+	RunningLimits   corev1.ResourceList
+}
+
+// GetPodResources returns the effective requests and limits of a pod, the way the scheduler computes them.
+// Each resource is the larger of the sum over the app containers and the highest init container, where
+// restartable (sidecar) init containers keep running alongside the containers started after them.
+// The pod overhead is added on top. A resource without a limit on any container has no limit.
+func GetPodResources(podSpec *corev1.PodSpec) (requests, limits corev1.ResourceList) {
+	requests = effectivePodResources(podSpec, func(resources corev1.ResourceRequirements) corev1.ResourceList {
+		return resources.Requests
+	})
+	limits = effectivePodResources(podSpec, func(resources corev1.ResourceRequirements) corev1.ResourceList {
+		return resources.Limits
+	})
+	for name, overhead := range podSpec.Overhead {
+		addResource(requests, name, overhead)
+		// Overhead only raises limits that are set, so that it doesn't turn an unlimited resource into a limited one.
+		if _, ok := limits[name]; ok {
+			addResource(limits, name, overhead)
+		}
+	}
+	return requests, limits
+}
+
+func effectivePodResources(podSpec *corev1.PodSpec, get func(corev1.ResourceRequirements) corev1.ResourceList) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		addResources(total, get(container.Resources))
+	}
+	sidecars := corev1.ResourceList{}
+	initPeak := corev1.ResourceList{}
+	for _, container := range podSpec.InitContainers {
+		running := corev1.ResourceList{}
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			// A sidecar runs for the whole life of the pod, alongside the app containers.
+			addResources(total, get(container.Resources))
+			addResources(sidecars, get(container.Resources))
+			addResources(running, sidecars)
+		} else {
+			addResources(running, get(container.Resources))
+			addResources(running, sidecars)
+		}
+		maxResources(initPeak, running)
+	}
+	maxResources(total, initPeak)
+	return total
+}
+
+func addResource(list corev1.ResourceList, name corev1.ResourceName, quantity resource.Quantity) {
+	if existing, ok := list[name]; ok {
+		existing.Add(quantity)
+		list[name] = existing
+		return
+	}
+	list[name] = quantity.DeepCopy()
+}
+
+func addResources(list, other corev1.ResourceList) {
+	for name, quantity := range other {
+		addResource(list, name, quantity)
+	}
+}
+
+func maxResources(list, other corev1.ResourceList) {
+	for name, quantity := range other {
+		if existing, ok := list[name]; !ok || quantity.Cmp(existing) > 0 {
+			list[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+func multiplyResources(list corev1.ResourceList, count int) corev1.ResourceList {
+	result := make(corev1.ResourceList, len(list))
+	for name, quantity := range list {
+		quantity = quantity.DeepCopy()
+		quantity.Mul(int64(count))
+		result[name] = quantity
+	}
+	return result
+}
+
+// setResources fills in Resources from PodSpec, Health and RunningPodCount.
+func (workload *Workload) setResources() {
+	if workload.PodSpec == nil {
+		workload.Resources = nil
+		return
+	}
+	requests, limits := GetPodResources(workload.PodSpec)
+	resources := &WorkloadResources{
+		PodRequests:     requests,
+		PodLimits:       limits,
+		RunningRequests: multiplyResources(requests, workload.RunningPodCount),
+		RunningLimits:   multiplyResources(limits, workload.RunningPodCount),
+	}
+	if workload.Health != nil {
+		resources.DesiredRequests = multiplyResources(requests, workload.Health.Desired)
+		resources.DesiredLimits = multiplyResources(limits, workload.Health.Desired)
+	}
+	workload.Resources = resources
+}
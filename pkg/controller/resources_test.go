@@ -0,0 +1,106 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func resourceList(values map[corev1.ResourceName]string) corev1.ResourceList {
+	list := corev1.ResourceList{}
+	for name, value := range values {
+		list[name] = resource.MustParse(value)
+	}
+	return list
+}
+
+func assertResources(t *testing.T, expected map[corev1.ResourceName]string, actual corev1.ResourceList) {
+	assert.Len(t, actual, len(expected))
+	for name, value := range expected {
+		quantity, ok := actual[name]
+		assert.True(t, ok, name)
+		expectedQuantity := resource.MustParse(value)
+		assert.Zero(t, expectedQuantity.Cmp(quantity), "%s: expected %s, got %s", name, value, quantity.String())
+	}
+}
+
+func TestGetPodResources(t *testing.T) {
+	always := corev1.ContainerRestartPolicyAlways
+	podSpec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{
+			Name: "migrate",
+			Resources: corev1.ResourceRequirements{
+				Requests: resourceList(map[corev1.ResourceName]string{"cpu": "2", "memory": "1Gi"}),
+				Limits:   resourceList(map[corev1.ResourceName]string{"memory": "1Gi"}),
+			},
+		}, {
+			Name:          "proxy",
+			RestartPolicy: &always,
+			Resources: corev1.ResourceRequirements{
+				Requests: resourceList(map[corev1.ResourceName]string{"cpu": "100m", "memory": "64Mi"}),
+				Limits:   resourceList(map[corev1.ResourceName]string{"cpu": "200m", "memory": "128Mi"}),
+			},
+		}, {
+			Name: "warmup",
+			Resources: corev1.ResourceRequirements{
+				Requests: resourceList(map[corev1.ResourceName]string{"cpu": "1500m", "ephemeral-storage": "2Gi"}),
+			},
+		}},
+		Containers: []corev1.Container{{
+			Name: "app",
+			Resources: corev1.ResourceRequirements{
+				Requests: resourceList(map[corev1.ResourceName]string{"cpu": "500m", "memory": "256Mi", "nvidia.com/gpu": "1"}),
+				Limits:   resourceList(map[corev1.ResourceName]string{"cpu": "1", "memory": "512Mi", "nvidia.com/gpu": "1"}),
+			},
+		}, {
+			Name: "worker",
+			Resources: corev1.ResourceRequirements{
+				Requests: resourceList(map[corev1.ResourceName]string{"cpu": "250m", "memory": "256Mi"}),
+			},
+		}},
+		Overhead: resourceList(map[corev1.ResourceName]string{"cpu": "50m", "memory": "32Mi"}),
+	}
+	requests, limits := GetPodResources(podSpec)
+	// cpu: max(2, 0.1+1.5, 0.5+0.25+0.1) + overhead; memory: max(1Gi, 64Mi, 256Mi+256Mi+64Mi) + overhead.
+	assertResources(t, map[corev1.ResourceName]string{
+		"cpu":               "2050m",
+		"memory":            "1056Mi",
+		"ephemeral-storage": "2Gi",
+		"nvidia.com/gpu":    "1",
+	}, requests)
+	assertResources(t, map[corev1.ResourceName]string{
+		"cpu":            "1250m",
+		"memory":         "1056Mi",
+		"nvidia.com/gpu": "1",
+	}, limits)
+
+	workload := Workload{PodSpec: podSpec, RunningPodCount: 2, Health: &WorkloadHealth{Desired: 3}}
+	workload.setResources()
+	assertResources(t, map[corev1.ResourceName]string{"cpu": "6150m", "memory": "3168Mi", "ephemeral-storage": "6Gi", "nvidia.com/gpu": "3"}, workload.Resources.DesiredRequests)
+	assertResources(t, map[corev1.ResourceName]string{"cpu": "2500m", "memory": "2112Mi", "nvidia.com/gpu": "2"}, workload.Resources.RunningLimits)
+
+	workload = Workload{PodSpec: podSpec}
+	workload.setResources()
+	assert.Nil(t, workload.Resources.DesiredRequests)
+	assertResources(t, map[corev1.ResourceName]string{"cpu": "0", "memory": "0", "ephemeral-storage": "0", "nvidia.com/gpu": "0"}, workload.Resources.RunningRequests)
+
+	workload = Workload{}
+	workload.setResources()
+	assert.Nil(t, workload.Resources)
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ContainerRestarts aggregates the restart counts of one container name across the pods of a workload.
+type ContainerRestarts struct {
+	// Total is the sum of restartCount over every pod.
+	Total int
+	// Max is the highest restartCount of a single pod.
+	Max int
+}
+
+// RestartSummary aggregates the container statuses of the pods of a workload, including init containers.
+type RestartSummary struct {
+	// Containers holds the restart counts keyed by container name.
+	Containers map[string]ContainerRestarts
+	// CrashLoopBackOff counts containers waiting with reason CrashLoopBackOff.
+	CrashLoopBackOff int
+	// ImagePullBackOff counts containers waiting with reason ImagePullBackOff or ErrImagePull.
+	ImagePullBackOff int
+	// CreateContainerConfigError counts containers waiting with reason CreateContainerConfigError.
+	CreateContainerConfigError int
+	// TerminationReasons counts the reason of the last termination of each container, e.g. OOMKilled or Error.
+	TerminationReasons map[string]int
+	// MostCommonTerminationReason is the reason with the highest count in TerminationReasons.
+	// Ties are broken alphabetically.
+	MostCommonTerminationReason string
+}
+
+func (summary *RestartSummary) add(pod unstructured.Unstructured) {
+	for _, field := range []string{"initContainerStatuses", "containerStatuses"} {
+		statuses, found, err := unstructured.NestedFieldNoCopy(pod.Object, "status", field)
+		if err != nil || !found {
+			continue
+		}
+		statusList, ok := statuses.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, statusI := range statusList {
+			status, ok := statusI.(map[string]interface{})
+			if ok {
+				summary.addContainer(status)
+			}
+		}
+	}
+}
+
+func (summary *RestartSummary) addContainer(status map[string]interface{}) {
+	name, _, _ := unstructured.NestedString(status, "name")
+	restartCount := nestedInt(status, "restartCount")
+	if summary.Containers == nil {
+		summary.Containers = map[string]ContainerRestarts{}
+	}
+	restarts := summary.Containers[name]
+	restarts.Total += restartCount
+	if restartCount > restarts.Max {
+		restarts.Max = restartCount
+	}
+	summary.Containers[name] = restarts
+
+	waitingReason, _, _ := unstructured.NestedString(status, "state", "waiting", "reason")
+	switch waitingReason {
+	case "CrashLoopBackOff":
+		summary.CrashLoopBackOff++
+	case "ImagePullBackOff", "ErrImagePull":
+		summary.ImagePullBackOff++
+	case "CreateContainerConfigError":
+		summary.CreateContainerConfigError++
+	}
+
+	terminationReason, _, _ := unstructured.NestedString(status, "lastState", "terminated", "reason")
+	if terminationReason == "" {
+		return
+	}
+	if summary.TerminationReasons == nil {
+		summary.TerminationReasons = map[string]int{}
+	}
+	summary.TerminationReasons[terminationReason]++
+	reasons := make([]string, 0, len(summary.TerminationReasons))
+	for reason := range summary.TerminationReasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	summary.MostCommonTerminationReason = ""
+	for _, reason := range reasons {
+		if summary.MostCommonTerminationReason == "" || summary.TerminationReasons[reason] > summary.TerminationReasons[summary.MostCommonTerminationReason] {
+			summary.MostCommonTerminationReason = reason
+		}
+	}
+}
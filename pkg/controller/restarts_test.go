@@ -0,0 +1,89 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func containerStatus(name string, restartCount int64, waitingReason, terminationReason string) interface{} {
+	status := map[string]interface{}{"name": name, "restartCount": restartCount}
+	if waitingReason != "" {
+		status["state"] = map[string]interface{}{"waiting": map[string]interface{}{"reason": waitingReason}}
+	}
+	if terminationReason != "" {
+		status["lastState"] = map[string]interface{}{"terminated": map[string]interface{}{"reason": terminationReason}}
+	}
+	return status
+}
+
+func TestRestartSummary(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	pods := client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("test")
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"}
+
+	crashing := newPodWithOwners("crashing", owner)
+	assert.NoError(t, unstructured.SetNestedSlice(crashing.Object, []interface{}{
+		containerStatus("init", 1, "", "Error"),
+	}, "status", "initContainerStatuses"))
+	assert.NoError(t, unstructured.SetNestedSlice(crashing.Object, []interface{}{
+		containerStatus("app", 7, "CrashLoopBackOff", "OOMKilled"),
+		containerStatus("sidecar", 0, "CreateContainerConfigError", ""),
+	}, "status", "containerStatuses"))
+	pulling := newPodWithOwners("pulling", owner)
+	assert.NoError(t, unstructured.SetNestedSlice(pulling.Object, []interface{}{
+		containerStatus("app", 2, "ErrImagePull", "OOMKilled"),
+		containerStatus("sidecar", 1, "ImagePullBackOff", "Error"),
+	}, "status", "containerStatuses"))
+	for _, pod := range []unstructured.Unstructured{crashing, pulling} {
+		_, err := pods.Create(context.TODO(), &pod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	dep := findWorkload(workloads, "dep")
+	assert.Nil(t, dep.Pods)
+	assert.Equal(t, RestartSummary{
+		Containers: map[string]ContainerRestarts{
+			"app":     {Total: 9, Max: 7},
+			"sidecar": {Total: 1, Max: 1},
+			"init":    {Total: 1, Max: 1},
+		},
+		CrashLoopBackOff:            1,
+		ImagePullBackOff:            2,
+		CreateContainerConfigError:  1,
+		TerminationReasons:          map[string]int{"OOMKilled": 2, "Error": 2},
+		MostCommonTerminationReason: "Error",
+	}, dep.Restarts)
+	assert.Empty(t, findWorkload(workloads, "dep-no-pods").Restarts.Containers)
+}
+
+func TestRestartSummaryFloatCounts(t *testing.T) {
+	// Pods decoded with encoding/json hold their numbers as float64.
+	pod := newPodWithOwners("decoded")
+	assert.NoError(t, unstructured.SetNestedSlice(pod.Object, []interface{}{
+		map[string]interface{}{"name": "app", "restartCount": float64(3)},
+	}, "status", "containerStatuses"))
+	summary := RestartSummary{}
+	summary.add(pod)
+	assert.Equal(t, map[string]ContainerRestarts{"app": {Total: 3, Max: 3}}, summary.Containers)
+}
@@ -0,0 +1,54 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PodStatusBreakdown counts the pods of a workload by phase and readiness.
+type PodStatusBreakdown struct {
+	// Phases counts pods per status.phase. Pods without a phase are counted under an empty phase.
+	Phases map[corev1.PodPhase]int
+	// Ready counts pods whose Ready condition is True.
+	Ready int
+	// ContainersReady counts pods whose ContainersReady condition is True.
+	ContainersReady int
+	// Terminating counts pods with a deletionTimestamp.
+	Terminating int
+}
+
+func (breakdown *PodStatusBreakdown) add(pod unstructured.Unstructured) {
+	if breakdown.Phases == nil {
+		breakdown.Phases = map[corev1.PodPhase]int{}
+	}
+	breakdown.Phases[corev1.PodPhase(getPodStatus(pod))]++
+	if getPodCondition(pod, corev1.PodReady) {
+		breakdown.Ready++
+	}
+	if getPodCondition(pod, corev1.ContainersReady) {
+		breakdown.ContainersReady++
+	}
+	if pod.GetDeletionTimestamp() != nil {
+		breakdown.Terminating++
+	}
+}
+
+// getPodCondition returns true if the pod has the condition with status True.
+func getPodCondition(pod unstructured.Unstructured, conditionType corev1.PodConditionType) bool {
+	condition := findCondition(pod.Object, string(conditionType))
+	return condition != nil && condition["status"] == string(corev1.ConditionTrue)
+}
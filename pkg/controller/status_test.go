@@ -0,0 +1,85 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func setPodStatus(t *testing.T, pod *unstructured.Unstructured, phase string, ready, containersReady bool) {
+	toStatus := func(value bool) string {
+		if value {
+			return "True"
+		}
+		return "False"
+	}
+	assert.NoError(t, unstructured.SetNestedField(pod.Object, phase, "status", "phase"))
+	assert.NoError(t, unstructured.SetNestedSlice(pod.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": toStatus(ready)},
+		map[string]interface{}{"type": "ContainersReady", "status": toStatus(containersReady)},
+	}, "status", "conditions"))
+}
+
+func TestPodStatusBreakdown(t *testing.T) {
+	breakdown := PodStatusBreakdown{}
+	breakdown.add(unstructured.Unstructured{Object: readFile(t, "./testdata/pod1.json")})
+	assert.Equal(t, PodStatusBreakdown{Phases: map[corev1.PodPhase]int{corev1.PodSucceeded: 1}}, breakdown)
+
+	client, _, _, _, _ := setupFakeData(t)
+	pods := client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("test")
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"}
+
+	ready := newPodWithOwners("ready", owner)
+	setPodStatus(t, &ready, "Running", true, true)
+	unready := newPodWithOwners("unready", owner)
+	setPodStatus(t, &unready, "Running", false, true)
+	terminating := newPodWithOwners("terminating", owner)
+	setPodStatus(t, &terminating, "Running", false, false)
+	now := metav1.Now()
+	terminating.SetDeletionTimestamp(&now)
+	failed := newPodWithOwners("failed", owner)
+	setPodStatus(t, &failed, "Failed", false, false)
+	for _, pod := range []unstructured.Unstructured{ready, unready, terminating, failed} {
+		_, err := pods.Create(context.TODO(), &pod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	for _, includePods := range []bool{false, true} {
+		var workloads []Workload
+		var err error
+		if includePods {
+			workloads, err = client.GetAllTopControllersWithPods("test")
+		} else {
+			workloads, err = client.GetAllTopControllersSummary("test")
+		}
+		assert.NoError(t, err)
+		dep := findWorkload(workloads, "dep")
+		assert.Equal(t, 5, dep.PodCount)
+		assert.Equal(t, 3, dep.RunningPodCount)
+		assert.Equal(t, PodStatusBreakdown{
+			Phases:          map[corev1.PodPhase]int{corev1.PodRunning: 3, corev1.PodFailed: 1, "": 1},
+			Ready:           1,
+			ContainersReady: 2,
+			Terminating:     1,
+		}, dep.PodStatus)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PodDistribution counts the pods of a workload per node, and per zone and region when Client.NodeTopology is set.
+type PodDistribution struct {
+	// Nodes counts pods per spec.nodeName. Pods that aren't scheduled yet are counted under an empty name.
+	Nodes map[string]int
+	// Zones and Regions count pods per topology.kubernetes.io/zone and topology.kubernetes.io/region label of their node.
+	// Pods on nodes without the label, or that aren't scheduled, are counted under an empty name.
+	Zones   map[string]int
+	Regions map[string]int
+	// SingleNode is set when there are at least two scheduled pods and they are all on the same node.
+	SingleNode bool
+	// SingleZone is set when at least two pods are in a known zone and it is the same zone.
+	SingleZone bool
+}
+
+// nodeTopology is the zone and region of a node.
+type nodeTopology struct {
+	zone   string
+	region string
+}
+
+func (distribution *PodDistribution) add(pod unstructured.Unstructured, nodes map[string]nodeTopology) {
+	nodeName, _, _ := unstructured.NestedString(pod.Object, "spec", "nodeName")
+	if distribution.Nodes == nil {
+		distribution.Nodes = map[string]int{}
+	}
+	distribution.Nodes[nodeName]++
+	distribution.SingleNode = isSingle(distribution.Nodes)
+	if nodes == nil {
+		return
+	}
+	if distribution.Zones == nil {
+		distribution.Zones = map[string]int{}
+		distribution.Regions = map[string]int{}
+	}
+	topology := nodes[nodeName]
+	distribution.Zones[topology.zone]++
+	distribution.Regions[topology.region]++
+	distribution.SingleZone = isSingle(distribution.Zones)
+}
+
+// isSingle returns true if at least two pods are counted under the same name, and no pod under another
+// name. Pods counted under an empty name are ignored.
+func isSingle(counts map[string]int) bool {
+	single, total := "", 0
+	for name, count := range counts {
+		if name == "" {
+			continue
+		}
+		if single != "" {
+			return false
+		}
+		single, total = name, count
+	}
+	return total > 1
+}
+
+// getNodeTopology lists the nodes of the cluster, and returns their zone and region by node name.
+func (client Client) getNodeTopology() (map[string]nodeTopology, error) {
+	nodes, err := client.listKind(schema.FromAPIVersionAndKind("v1", "Node"), "", metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	topology := make(map[string]nodeTopology, len(nodes))
+	for _, node := range nodes {
+		labels := node.GetLabels()
+		topology[node.GetName()] = nodeTopology{
+			zone:   labels[corev1.LabelTopologyZone],
+			region: labels[corev1.LabelTopologyRegion],
+		}
+	}
+	return topology, nil
+}
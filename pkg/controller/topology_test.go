@@ -0,0 +1,105 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestPodDistribution(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	client.RESTMapper.(*meta.DefaultRESTMapper).Add(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, meta.RESTScopeRoot)
+	nodes := client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "nodes"})
+	for name, zone := range map[string]string{"node-a": "zone-1", "node-b": "zone-1", "node-c": "zone-2"} {
+		node := unstructured.Unstructured{}
+		node.SetAPIVersion("v1")
+		node.SetKind("Node")
+		node.SetName(name)
+		node.SetLabels(map[string]string{
+			"topology.kubernetes.io/zone":   zone,
+			"topology.kubernetes.io/region": "region",
+		})
+		_, err := nodes.Create(context.TODO(), &node, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+	pods := client.Dynamic.Resource(schema.GroupVersionResource{Version: "v1", Resource: "pods"}).Namespace("test")
+	owner := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"}
+	for name, nodeName := range map[string]string{"on-a": "node-a", "on-b": "node-b"} {
+		pod := newPodWithOwners(name, owner)
+		assert.NoError(t, unstructured.SetNestedField(pod.Object, nodeName, "spec", "nodeName"))
+		_, err := pods.Create(context.TODO(), &pod, metav1.CreateOptions{})
+		assert.NoError(t, err)
+	}
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	dep := findWorkload(workloads, "dep")
+	assert.Equal(t, PodDistribution{Nodes: map[string]int{"": 1, "node-a": 1, "node-b": 1}}, dep.Distribution)
+
+	client.NodeTopology = true
+	workloads, err = client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	dep = findWorkload(workloads, "dep")
+	assert.Equal(t, PodDistribution{
+		Nodes:      map[string]int{"": 1, "node-a": 1, "node-b": 1},
+		Zones:      map[string]int{"": 1, "zone-1": 2},
+		Regions:    map[string]int{"": 1, "region": 2},
+		SingleZone: true,
+	}, dep.Distribution)
+}
+
+func TestPodDistributionWithoutNodes(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	client.RESTMapper.(*meta.DefaultRESTMapper).Add(schema.GroupVersionKind{Version: "v1", Kind: "Node"}, meta.RESTScopeRoot)
+	client.Dynamic.(*fake.FakeDynamicClient).PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", errors.New("no RBAC"))
+	})
+	pod := newPodWithOwners("on-a", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs"})
+	assert.NoError(t, unstructured.SetNestedField(pod.Object, "node-a", "spec", "nodeName"))
+	createObject(t, client, podsResource, pod)
+	client.NodeTopology = true
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	dep := findWorkload(workloads, "dep")
+	assert.Equal(t, PodDistribution{Nodes: map[string]int{"": 1, "node-a": 1}}, dep.Distribution)
+
+	result, err := client.DiscoverWorkloads(Filter{Namespaces: []string{"test"}}, false)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, result.Err(), ErrForbidden)
+	failure := result.Failures[len(result.Failures)-1]
+	assert.Equal(t, "Node", failure.GroupVersionKind.Kind)
+	assert.ErrorIs(t, failure, ErrForbidden)
+}
+
+func TestIsSingle(t *testing.T) {
+	assert.False(t, isSingle(map[string]int{}))
+	assert.False(t, isSingle(map[string]int{"node-a": 1}))
+	assert.True(t, isSingle(map[string]int{"node-a": 2, "": 3}))
+	assert.False(t, isSingle(map[string]int{"node-a": 2, "node-b": 1}))
+	assert.False(t, isSingle(map[string]int{"": 4}))
+}
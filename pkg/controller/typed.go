@@ -0,0 +1,151 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// KindMismatchError is returned by the typed accessors of Workload when TopController is of another kind.
+type KindMismatchError struct {
+	// Expected is the kind that was asked for. Its group is empty if the type isn't a known controller type.
+	Expected schema.GroupKind
+	// Actual is the kind of TopController.
+	Actual schema.GroupVersionKind
+}
+
+func (err *KindMismatchError) Error() string {
+	return fmt.Sprintf("top controller is a %s, not a %s", err.Actual.GroupKind().String(), err.Expected.String())
+}
+
+// typedKinds are the group and kind of the built-in controller types, and of pods for naked pods.
+var typedKinds = map[reflect.Type]schema.GroupKind{
+	reflect.TypeOf(appsv1.Deployment{}):  {Group: "apps", Kind: "Deployment"},
+	reflect.TypeOf(appsv1.ReplicaSet{}):  {Group: "apps", Kind: "ReplicaSet"},
+	reflect.TypeOf(appsv1.StatefulSet{}): {Group: "apps", Kind: "StatefulSet"},
+	reflect.TypeOf(appsv1.DaemonSet{}):   {Group: "apps", Kind: "DaemonSet"},
+	reflect.TypeOf(batchv1.Job{}):        {Group: "batch", Kind: "Job"},
+	reflect.TypeOf(batchv1.CronJob{}):    {Group: "batch", Kind: "CronJob"},
+	reflect.TypeOf(corev1.Pod{}):         {Kind: "Pod"},
+}
+
+// typedObjects holds the objects TopController has been decoded into, by type.
+type typedObjects struct {
+	mu      sync.Mutex
+	objects map[reflect.Type]*typedObject
+}
+
+// typedObject is TopController decoded into one type. It is decoded once, outside of typedObjects.mu.
+type typedObject struct {
+	once   sync.Once
+	object any
+	err    error
+}
+
+func newTypedObjects() *typedObjects {
+	return &typedObjects{objects: map[reflect.Type]*typedObject{}}
+}
+
+// get returns the entry for a type, adding it if it's missing.
+func (typed *typedObjects) get(objectType reflect.Type) *typedObject {
+	typed.mu.Lock()
+	defer typed.mu.Unlock()
+	entry, ok := typed.objects[objectType]
+	if !ok {
+		entry = &typedObject{}
+		typed.objects[objectType] = entry
+	}
+	return entry
+}
+
+// As decodes the TopController of a workload into T, e.g. As[appsv1.Deployment](&workload).
+// For the built-in controller types the group and kind must match, for other types only the kind is
+// compared with the name of the type. A *KindMismatchError is returned if they don't.
+// For the workloads returned by this package the decoded object is cached and shared by later calls and
+// copies of the workload, so it is read-only, and it isn't refreshed if TopController is replaced afterwards.
+// Workloads built by the caller are decoded again on every call.
+func As[T any](workload *Workload) (*T, error) {
+	objectType := reflect.TypeOf((*T)(nil)).Elem()
+	actual := workload.TopController.GroupVersionKind()
+	expected, known := typedKinds[objectType]
+	if !known {
+		expected = schema.GroupKind{Kind: objectType.Name()}
+	}
+	if actual.Kind != expected.Kind || (known && actual.Group != expected.Group) {
+		return nil, &KindMismatchError{Expected: expected, Actual: actual}
+	}
+	decode := func() (*T, error) {
+		object := new(T)
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(workload.TopController.Object, object)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s %s/%s: %w", actual.Kind, workload.TopController.GetNamespace(), workload.TopController.GetName(), err)
+		}
+		return object, nil
+	}
+	if workload.typed == nil {
+		return decode()
+	}
+	entry := workload.typed.get(objectType)
+	entry.once.Do(func() {
+		entry.object, entry.err = decode()
+	})
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return entry.object.(*T), nil
+}
+
+// AsDeployment decodes the TopController of a workload into a Deployment, see As.
+func (workload *Workload) AsDeployment() (*appsv1.Deployment, error) {
+	return As[appsv1.Deployment](workload)
+}
+
+// AsReplicaSet decodes the TopController of a workload into a ReplicaSet, see As.
+func (workload *Workload) AsReplicaSet() (*appsv1.ReplicaSet, error) {
+	return As[appsv1.ReplicaSet](workload)
+}
+
+// AsStatefulSet decodes the TopController of a workload into a StatefulSet, see As.
+func (workload *Workload) AsStatefulSet() (*appsv1.StatefulSet, error) {
+	return As[appsv1.StatefulSet](workload)
+}
+
+// AsDaemonSet decodes the TopController of a workload into a DaemonSet, see As.
+func (workload *Workload) AsDaemonSet() (*appsv1.DaemonSet, error) {
+	return As[appsv1.DaemonSet](workload)
+}
+
+// AsJob decodes the TopController of a workload into a Job, see As.
+func (workload *Workload) AsJob() (*batchv1.Job, error) {
+	return As[batchv1.Job](workload)
+}
+
+// AsCronJob decodes the TopController of a workload into a CronJob, see As.
+func (workload *Workload) AsCronJob() (*batchv1.CronJob, error) {
+	return As[batchv1.CronJob](workload)
+}
+
+// AsPod decodes the TopController of a naked pod's workload into a Pod, see As.
+func (workload *Workload) AsPod() (*corev1.Pod, error) {
+	return As[corev1.Pod](workload)
+}
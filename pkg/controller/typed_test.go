@@ -0,0 +1,109 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type ScaledJob struct {
+	Spec struct {
+		JobTargetRef map[string]interface{} `json:"jobTargetRef"`
+	} `json:"spec"`
+}
+
+func TestTypedAccessors(t *testing.T) {
+	workload := Workload{TopController: unstructured.Unstructured{Object: readFile(t, "./testdata/deployment.json")}, typed: newTypedObjects()}
+	deployment, err := workload.AsDeployment()
+	assert.NoError(t, err)
+	assert.Equal(t, "dep", deployment.Name)
+	assert.Equal(t, "container", deployment.Spec.Template.Spec.Containers[0].Name)
+	again, err := workload.AsDeployment()
+	assert.NoError(t, err)
+	assert.Same(t, deployment, again)
+
+	// Workloads built by the caller aren't cached.
+	uncached := Workload{TopController: workload.TopController}
+	first, err := uncached.AsDeployment()
+	assert.NoError(t, err)
+	second, err := uncached.AsDeployment()
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.NotSame(t, first, second)
+
+	_, err = workload.AsCronJob()
+	var mismatch *KindMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, schema.GroupKind{Group: "batch", Kind: "CronJob"}, mismatch.Expected)
+	assert.Equal(t, "Deployment", mismatch.Actual.Kind)
+	assert.EqualError(t, err, "top controller is a Deployment.apps, not a CronJob.batch")
+
+	workload = Workload{TopController: unstructured.Unstructured{Object: readFile(t, "./testdata/cronjob.json")}}
+	cronJob, err := workload.AsCronJob()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", cronJob.Name)
+	_, err = workload.AsJob()
+	assert.ErrorAs(t, err, &mismatch)
+
+	workload = Workload{TopController: newScaledJob("scaled")}
+	scaledJob, err := As[ScaledJob](&workload)
+	assert.NoError(t, err)
+	assert.Contains(t, scaledJob.Spec.JobTargetRef, "template")
+	_, err = workload.AsDeployment()
+	assert.ErrorAs(t, err, &mismatch)
+}
+
+func TestTypedAccessorsConcurrent(t *testing.T) {
+	workload := Workload{TopController: unstructured.Unstructured{Object: readFile(t, "./testdata/deployment.json")}, typed: newTypedObjects()}
+	copied := workload
+	deployments := make([]interface{}, 10)
+	var wg sync.WaitGroup
+	for i := range deployments {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				deployments[i], _ = workload.AsDeployment()
+			} else {
+				deployments[i], _ = copied.AsDeployment()
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, deployment := range deployments {
+		assert.Same(t, deployments[0], deployment)
+	}
+}
+
+func TestTypedAccessorsNakedPod(t *testing.T) {
+	client, _, _, _, _ := setupFakeData(t)
+	naked := newPodWithOwners("naked")
+	createObject(t, client, podsResource, naked)
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	workload := findWorkload(workloads, "naked")
+	pod, err := workload.AsPod()
+	assert.NoError(t, err)
+	assert.Equal(t, "naked", pod.Name)
+	again, err := workload.AsPod()
+	assert.NoError(t, err)
+	assert.Same(t, pod, again)
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// WorkloadID identifies an object. Unlike its kind, namespace and name, it tells apart kinds with the
+// same name in different API groups, and an object from one that was deleted and created again.
+type WorkloadID struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+	UID       types.UID
+}
+
+// NewWorkloadID returns the ID of an object.
+func NewWorkloadID(object unstructured.Unstructured) WorkloadID {
+	return WorkloadID{
+		Group:     object.GroupVersionKind().Group,
+		Kind:      object.GetKind(),
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		UID:       object.GetUID(),
+	}
+}
+
+// ownerWorkloadID returns the ID of the owner that an object in namespace refers to.
+// The UID is empty if the owner reference doesn't have one.
+func ownerWorkloadID(namespace string, owner metav1.OwnerReference) WorkloadID {
+	return WorkloadID{
+		Group:     schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind).Group,
+		Kind:      owner.Kind,
+		Namespace: namespace,
+		Name:      owner.Name,
+		UID:       owner.UID,
+	}
+}
+
+// String formats the ID as Kind.group/namespace/name@uid, leaving out the parts that are empty.
+func (id WorkloadID) String() string {
+	name := fmt.Sprintf("%s/%s", schema.GroupKind{Group: id.Group, Kind: id.Kind}.String(), objectName(id.Namespace, id.Name))
+	if id.UID != "" {
+		name += "@" + string(id.UID)
+	}
+	return name
+}
+
+// withoutUID returns the ID with an empty UID, which matches any object with the same name.
+func (id WorkloadID) withoutUID() WorkloadID {
+	id.UID = ""
+	return id
+}
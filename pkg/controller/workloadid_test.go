@@ -0,0 +1,169 @@
+// Copyright 2020 FairwindsOps Inc
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWorkloadID(t *testing.T) {
+	deployment := unstructured.Unstructured{Object: readFile(t, "./testdata/deployment.json")}
+	deployment.SetNamespace("test")
+	deployment.SetUID("1234")
+	id := NewWorkloadID(deployment)
+	assert.Equal(t, WorkloadID{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "dep", UID: "1234"}, id)
+	assert.Equal(t, "Deployment.apps/test/dep@1234", id.String())
+	assert.Equal(t, "Node/node-a", WorkloadID{Kind: "Node", Name: "node-a"}.String())
+
+	client, pod, _, _, _ := setupFakeData(t)
+	legacy := map[string]unstructured.Unstructured{}
+	_, err := client.GetTopController(pod, legacy)
+	assert.NoError(t, err)
+	assert.Contains(t, legacy, "ReplicaSet/test/rs")
+	assert.Contains(t, legacy, "Deployment/test/dep")
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	dep := findWorkload(workloads, "dep")
+	assert.Equal(t, NewWorkloadID(dep.TopController), dep.ID)
+}
+
+func TestWorkloadIDSeparatesGroups(t *testing.T) {
+	rollouts := []schema.GroupVersionKind{
+		{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"},
+		{Group: "flagger.app", Version: "v1beta1", Kind: "Rollout"},
+	}
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, meta.RESTScopeNamespace)
+	listKinds := map[schema.GroupVersionResource]string{podsResource: "PodList"}
+	registry := &KindRegistry{}
+	for _, rollout := range rollouts {
+		restMapper.Add(rollout, meta.RESTScopeNamespace)
+		listKinds[rollout.GroupVersion().WithResource("rollouts")] = "RolloutList"
+		registry.Register(KindInfo{GroupVersionKind: rollout, TopLevel: true})
+	}
+	client := Client{
+		Context:    context.TODO(),
+		Dynamic:    fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds),
+		RESTMapper: restMapper,
+		Registry:   registry,
+	}
+	for idx, rollout := range rollouts {
+		object := unstructured.Unstructured{}
+		object.SetGroupVersionKind(rollout)
+		object.SetNamespace("test")
+		object.SetName("web")
+		createObject(t, client, rollout.GroupVersion().WithResource("rollouts"), object)
+		apiVersion, kind := rollout.ToAPIVersionAndKind()
+		pod := newPodWithOwners([]string{"argo-pod", "flagger-pod"}[idx], metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: "web"})
+		createObject(t, client, podsResource, pod)
+	}
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 2)
+	for _, workload := range workloads {
+		assert.Equal(t, 1, workload.PodCount)
+		assert.Equal(t, "web", workload.ID.Name)
+	}
+	assert.NotEqual(t, workloads[0].ID, workloads[1].ID)
+
+	workloadCache, err := NewWorkloadCache(client, "test", 0)
+	assert.NoError(t, err)
+	startWorkloadCache(t, workloadCache)
+	workloads, err = workloadCache.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	assert.Len(t, workloads, 2)
+	for _, workload := range workloads {
+		assert.Equal(t, 1, workload.PodCount)
+	}
+
+	graph, err := client.GetOwnerGraph("test")
+	assert.NoError(t, err)
+	assert.Equal(t, []OwnerGraphEdge{
+		{Owner: "Rollout.argoproj.io/test/web", Owned: "Pod/test/argo-pod"},
+		{Owner: "Rollout.flagger.app/test/web", Owned: "Pod/test/flagger-pod"},
+	}, graph.Edges)
+}
+
+func TestWorkloadIDSeparatesRecreatedControllers(t *testing.T) {
+	client, _, rs, dep, _ := setupFakeData(t)
+	dynamic := client.Dynamic.(*fake.FakeDynamicClient)
+	dep.SetUID("old")
+	_, err := dynamic.Resource(deploymentsResource).Namespace("test").Update(context.TODO(), &dep, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	rs.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "dep", UID: "new"}})
+	_, err = dynamic.Resource(replicaSetsResource).Namespace("test").Update(context.TODO(), &rs, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	// The deployment is deleted and created again once it has been listed up front.
+	dynamic.PrependReactor("list", "replicasets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		recreated := dep.DeepCopy()
+		recreated.SetUID("new")
+		return false, nil, dynamic.Tracker().Update(deploymentsResource, recreated, "test")
+	})
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	podCounts := map[WorkloadID]int{}
+	for _, workload := range workloads {
+		if workload.ID.Name == "dep" {
+			podCounts[workload.ID] = workload.PodCount
+		}
+	}
+	assert.Equal(t, map[WorkloadID]int{
+		{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "dep", UID: "old"}: 0,
+		{Group: "apps", Kind: "Deployment", Namespace: "test", Name: "dep", UID: "new"}: 1,
+	}, podCounts)
+}
+
+func TestWorkloadIDDoesNotAdoptRecreatedControllers(t *testing.T) {
+	client, pod, rs, dep, _ := setupFakeData(t)
+	dynamic := client.Dynamic.(*fake.FakeDynamicClient)
+	// Only a deployment with the old owner's name, but another UID, exists.
+	dep.SetUID("new")
+	_, err := dynamic.Resource(deploymentsResource).Namespace("test").Update(context.TODO(), &dep, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	rs.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "dep", UID: "old"}})
+	_, err = dynamic.Resource(replicaSetsResource).Namespace("test").Update(context.TODO(), &rs, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+
+	controller, err := client.GetTopController(pod, nil)
+	assert.ErrorIs(t, err, ErrOwnerNotFound)
+	assert.Equal(t, "ReplicaSet", controller.GetKind())
+
+	workloads, err := client.GetAllTopControllersSummary("test")
+	assert.NoError(t, err)
+	recreated := findWorkload(workloads, "dep")
+	assert.Equal(t, types.UID("new"), recreated.ID.UID)
+	assert.Equal(t, 0, recreated.PodCount)
+
+	report, err := client.GetOrphanReport("test")
+	assert.NoError(t, err)
+	assert.Len(t, report.UIDMismatches, 1)
+	assert.Equal(t, "rs", report.UIDMismatches[0].Object.Name)
+	assert.Equal(t, types.UID("new"), report.UIDMismatches[0].FoundUID)
+	assert.Empty(t, report.OrphanedControllers)
+}